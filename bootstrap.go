@@ -0,0 +1,290 @@
+package gomake
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultCacheDir is the directory rebuilt Gomakefile binaries are cached
+	// in by default, relative to the directory the source is found in.
+	DefaultCacheDir = ".gomake"
+
+	// bootstrapEnv is set to the hash of the source when a rebuilt binary is
+	// run, so that a binary that doesn't embed the hash isn't rebuilt and run
+	// forever. It's removed from the environment straight away so commands run
+	// by rules don't inherit it.
+	bootstrapEnv = "GOMAKE_BOOTSTRAP"
+)
+
+var (
+	// bootstrapHash is the hash of the source the running binary was built
+	// from, set with -ldflags -X when Bootstrap builds the binary.
+	bootstrapHash string
+
+	// bootstrapHashVar is the name of bootstrapHash passed to the linker.
+	bootstrapHashVar = reflect.TypeOf(Bootstrapper{}).PkgPath() + ".bootstrapHash"
+
+	// errNotBuildable is returned by build when go can't load the source
+	// package, such as in module mode without a go.mod.
+	errNotBuildable = errors.New("source can't be built")
+)
+
+// Bootstrapper rebuilds a Gomakefile binary from its source when the source
+// has changed, and runs the rebuilt binary in place of the running one.
+type Bootstrapper struct {
+	// Source is the directory of the Gomakefile's main package, such as
+	// "cmd/gomake". A relative Source is looked for in the current directory
+	// and then each of its parents.
+	Source string
+	// CacheDir is the directory binaries are built in, defaulting to
+	// DefaultCacheDir.
+	CacheDir string
+	// Tags are the build tags to build the binary with.
+	Tags []string
+}
+
+// Bootstrap rebuilds the Gomakefile binary from the main package in source if
+// it has changed and runs it with the same arguments in place of the running
+// binary. See Bootstrapper.Bootstrap.
+func Bootstrap(source string) error {
+	bootstrapper := &Bootstrapper{
+		Source: source,
+	}
+
+	return bootstrapper.Bootstrap()
+}
+
+// Bootstrap hashes the Go files of the source package and, unless the running
+// binary was built from the same hash, runs the binary built from it with the
+// same arguments and environment in place of the running one. The binary is
+// built with the go command on the PATH the first time and cached by hash, so
+// Bootstrap only returns if the running binary is up to date, the source can't
+// be found or built, or on errors, in which case the running binary can carry
+// on with its stale rules.
+//
+// Only the files of the source package, go.mod and go.sum are hashed, so
+// changes to other packages it imports aren't noticed.
+func (b *Bootstrapper) Bootstrap() error {
+	bootstrapped := os.Getenv(bootstrapEnv)
+	os.Unsetenv(bootstrapEnv)
+
+	root, dir, ok := b.resolve()
+	if !ok {
+		return nil
+	}
+
+	// Without a go command the binary can't be rebuilt
+	version, err := goVersion(root)
+	if err != nil {
+		return nil
+	}
+
+	hash, err := b.hash(root, dir, version)
+	if err != nil {
+		return err
+	}
+
+	// The running binary was built from the same source
+	if bootstrapHash == hash {
+		return nil
+	}
+
+	if bootstrapped == hash {
+		return fmt.Errorf("rebuilt binary doesn't set %s", bootstrapHashVar)
+	}
+
+	path, err := b.build(root, dir, hash)
+	if errors.Is(err, errNotBuildable) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	env := append(os.Environ(), fmt.Sprintf("%s=%s", bootstrapEnv, hash))
+	return execBinary(path, os.Args, env)
+}
+
+// resolve returns the directory the source is found in and the source
+// directory itself, or false if it can't be found.
+func (b *Bootstrapper) resolve() (root, dir string, ok bool) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", "", false
+	}
+
+	if filepath.IsAbs(b.Source) {
+		return wd, b.Source, isDir(b.Source)
+	}
+
+	for root = wd; ; {
+		dir = filepath.Join(root, b.Source)
+		if isDir(dir) {
+			return root, dir, true
+		}
+
+		parent := filepath.Dir(root)
+		if parent == root {
+			return "", "", false
+		}
+		root = parent
+	}
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// goVersion returns the version of the go command that builds in root, which
+// may differ from the version the running binary was built with.
+func goVersion(root string) (string, error) {
+	cmd := exec.Command("go", "env", "GOVERSION")
+	cmd.Dir = root
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// hash returns a hash of the source package's Go files, the module files in
+// root, the build tags and the Go version.
+func (b *Bootstrapper) hash(root, dir, version string) (string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return "", err
+	}
+
+	if len(paths) == 0 {
+		return "", fmt.Errorf("no Go files in %s", b.Source)
+	}
+
+	paths = append(paths, filepath.Join(root, "go.mod"), filepath.Join(root, "go.sum"))
+	sort.Strings(paths)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", version, strings.Join(b.Tags, ","))
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		// Hash relative paths so moving the checkout doesn't rebuild
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			name = path
+		}
+
+		fmt.Fprintf(h, "%s %d\n", filepath.ToSlash(name), len(data))
+		h.Write(data)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+// build returns the path of the binary built from the source in dir with
+// hash, building it in root if it isn't cached. Binaries built from other
+// hashes are removed.
+func (b *Bootstrapper) build(root, dir, hash string) (string, error) {
+	cacheDir := b.CacheDir
+	if cacheDir == "" {
+		cacheDir = DefaultCacheDir
+	}
+	if !filepath.IsAbs(cacheDir) {
+		cacheDir = filepath.Join(root, cacheDir)
+	}
+
+	name := "gomake-" + hash
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	path := filepath.Join(cacheDir, name)
+	_, err := os.Stat(path)
+	if err == nil {
+		return path, nil
+	}
+
+	err = os.MkdirAll(cacheDir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	// Build to a temporary file so that a failed build isn't cached
+	tmp := path + ".tmp"
+	args := []string{
+		"build",
+		"-o", tmp,
+		"-ldflags", fmt.Sprintf("-X %s=%s", bootstrapHashVar, hash),
+	}
+	if len(b.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(b.Tags, ","))
+	}
+
+	// Build the package by its path relative to root, as go build doesn't take
+	// absolute paths outside of GOPATH without a module
+	source, err := filepath.Rel(root, dir)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(source, "..") {
+		source = "." + string(filepath.Separator) + source
+	}
+
+	// Check the package loads first so that a source that can't be built,
+	// such as in module mode without a go.mod, is skipped quietly
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", "list", source)
+	cmd.Dir = root
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", errNotBuildable, strings.TrimSpace(stderr.String()))
+	}
+
+	cmd = exec.Command("go", append(args, source)...)
+	cmd.Dir = root
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("rebuilding %s: %w", b.Source, err)
+	}
+
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return "", err
+	}
+
+	// Remove stale binaries
+	stale, _ := filepath.Glob(filepath.Join(cacheDir, "gomake-*"))
+	for _, binary := range stale {
+		if filepath.Base(binary) != name {
+			os.Remove(binary)
+		}
+	}
+
+	return path, nil
+}
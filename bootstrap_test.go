@@ -0,0 +1,245 @@
+package gomake
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chdir changes the working directory to dir until the test finishes.
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	t.Cleanup(func() {
+		os.Chdir(wd)
+	})
+}
+
+func writeBootstrapSource(t *testing.T, dir, msg string) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	src := "package main\n\nfunc main() { println(\"" + msg + "\") }\n"
+	err = os.WriteFile(filepath.Join(dir, "main.go"), []byte(src), 0644)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+}
+
+// resolveHash returns the hash of b's source.
+func resolveHash(t *testing.T, b *Bootstrapper) string {
+	root, dir, ok := b.resolve()
+	if !ok {
+		t.Fatalf("Expected %s to be found", b.Source)
+	}
+
+	version, err := goVersion(root)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	hash, err := b.hash(root, dir, version)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	return hash
+}
+
+func TestBootstrapHash(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeBootstrapSource(t, "make", "hello")
+
+	b := &Bootstrapper{Source: "make"}
+	first := resolveHash(t, b)
+
+	// Tests aren't part of the binary
+	err := os.WriteFile(filepath.Join("make", "main_test.go"), []byte("package main\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	second := resolveHash(t, b)
+	if first != second {
+		t.Errorf("Expected %s but got %s", first, second)
+	}
+
+	// The hash is the same from any directory
+	chdir(t, "make")
+	third := resolveHash(t, b)
+	if first != third {
+		t.Errorf("Expected %s but got %s", first, third)
+	}
+
+	writeBootstrapSource(t, ".", "goodbye")
+	fourth := resolveHash(t, b)
+	if first == fourth {
+		t.Errorf("Expected hash to change from %s", first)
+	}
+
+	b.Tags = []string{"integration"}
+	fifth := resolveHash(t, b)
+	if fourth == fifth {
+		t.Errorf("Expected hash to change with tags from %s", fourth)
+	}
+
+	// The hash is of the go command's version, not the running binary's
+	root, dir, _ := b.resolve()
+	sixth, err := b.hash(root, dir, "go0.1")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+	if fifth == sixth {
+		t.Errorf("Expected hash to change with the Go version from %s", fifth)
+	}
+}
+
+func TestBootstrapNoSource(t *testing.T) {
+	chdir(t, t.TempDir())
+
+	err := Bootstrap("missing")
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	err = os.MkdirAll("empty", 0755)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	err = Bootstrap("empty")
+	if err == nil {
+		t.Errorf("Expected err but got nil")
+	}
+}
+
+func TestBootstrapNotBuildable(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go list in short mode")
+	}
+
+	// Module mode without a go.mod can't build the source
+	chdir(t, t.TempDir())
+	t.Setenv("GO111MODULE", "on")
+	writeBootstrapSource(t, "make", "hello")
+
+	err := Bootstrap("make")
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	binaries, _ := filepath.Glob(filepath.Join(DefaultCacheDir, "gomake-*"))
+	if len(binaries) != 0 {
+		t.Errorf("Expected no cached binaries but got %v", binaries)
+	}
+
+	// Without a go command there's nothing to build with
+	t.Setenv("PATH", "")
+	err = Bootstrap("make")
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+}
+
+func TestBootstrapUpToDate(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeBootstrapSource(t, "make", "hello")
+
+	b := &Bootstrapper{Source: "make"}
+	hash := resolveHash(t, b)
+
+	previous := bootstrapHash
+	bootstrapHash = hash
+	defer func() {
+		bootstrapHash = previous
+	}()
+
+	t.Setenv(bootstrapEnv, "stale")
+	err := b.Bootstrap()
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	_, err = os.Stat(DefaultCacheDir)
+	if !os.IsNotExist(err) {
+		t.Errorf("Expected %s not to be created", DefaultCacheDir)
+	}
+
+	// Commands run by rules don't inherit the bootstrap hash
+	_, ok := os.LookupEnv(bootstrapEnv)
+	if ok {
+		t.Errorf("Expected %s to be unset", bootstrapEnv)
+	}
+}
+
+func TestBootstrapNotEmbedded(t *testing.T) {
+	chdir(t, t.TempDir())
+	writeBootstrapSource(t, "make", "hello")
+
+	b := &Bootstrapper{Source: "make"}
+	t.Setenv(bootstrapEnv, resolveHash(t, b))
+
+	// A rebuilt binary without the hash isn't rebuilt and run again
+	err := b.Bootstrap()
+	if err == nil {
+		t.Errorf("Expected err but got nil")
+	}
+}
+
+func TestBootstrapBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping go build in short mode")
+	}
+
+	chdir(t, t.TempDir())
+	err := os.WriteFile("go.mod", []byte("module example.com/bootstrap\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+	writeBootstrapSource(t, "make", "hello")
+
+	// Binaries are cached beside the source from any directory
+	chdir(t, "make")
+
+	b := &Bootstrapper{Source: "make"}
+	root, dir, _ := b.resolve()
+	path, err := b.build(root, dir, resolveHash(t, b))
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	expected := filepath.Join(root, DefaultCacheDir)
+	if filepath.Dir(path) != expected {
+		t.Errorf("Expected %s but got %s", expected, filepath.Dir(path))
+	}
+
+	_, err = os.Stat(path)
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	// A changed source is rebuilt and the stale binary removed
+	writeBootstrapSource(t, ".", "goodbye")
+	rebuilt, err := b.build(root, dir, resolveHash(t, b))
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+	if rebuilt == path {
+		t.Errorf("Expected a new binary but got %s", rebuilt)
+	}
+
+	binaries, _ := filepath.Glob(filepath.Join(expected, "gomake-*"))
+	if len(binaries) != 1 {
+		t.Errorf("Expected 1 cached binary but got %v", binaries)
+	}
+}
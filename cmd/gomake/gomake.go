@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/exec"
 
 	"github.com/hinshun/gomake"
 )
@@ -13,45 +12,46 @@ const (
 )
 
 func main() {
-	err := gomake.Gomake(NewGomakefile()).Run(os.Args)
+	err := gomake.Bootstrap("cmd/gomake")
 	if err != nil {
-		fmt.Printf("%s", err)
-		os.Exit(-1)
+		fmt.Fprintf(os.Stderr, "bootstrap: %s\n", err)
 	}
+
+	gomake.Gomake(NewGomakefile()).RunAndExit(os.Args)
 }
 
 func NewGomakefile() *gomake.Gomakefile {
 	gomakefile := gomake.NewGomakefile()
 
-	rebuild := gomakefile.AddRule("gomake", nil, func() error {
-		cmd := exec.Command("go", "build", "cmd/gomake/gomake.go")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-
-		return cmd.Run()
+	rebuild := gomakefile.AddAction("gomake", nil, func(ctx *gomake.Context) error {
+		return gomake.Run(ctx, "go", "build", "cmd/gomake/gomake.go")
 	})
 	rebuild.Description = "Rebuilds gomake"
+	rebuild.Inputs = []string{"*.go", "cmd/...", "pkg/..."}
 
-	test := gomakefile.AddRule("test", nil, func() error {
-		cmd := exec.Command("go", "test", "./...")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-
-		return cmd.Run()
+	test := gomakefile.AddAction("test", nil, func(ctx *gomake.Context) error {
+		return gomake.Run(ctx, "go", "test", "./...")
 	})
 	test.Description = "Tests all the packages"
+	test.Inputs = []string{"*.go", "cmd/...", "pkg/..."}
+
+	clean := gomakefile.AddAction("clean", nil, func(ctx *gomake.Context) error {
+		if ctx.DryRun {
+			fmt.Fprintf(ctx.Stdout, "+ rm gomake\n")
+			return nil
+		}
 
-	clean := gomakefile.AddRule("clean", nil, func() error {
 		err := os.Remove("gomake")
 		if err != nil {
-			fmt.Printf("%s\n", err)
+			fmt.Fprintf(ctx.Stderr, "%s\n", err)
 		}
 
 		return nil
 	})
 	clean.Description = "Removes gomake"
 
-	gomakefile.Targets[""] = rebuild
+	gomakefile.SetDefault(rebuild)
+	gomakefile.AddAlias("build", rebuild)
 
 	return gomakefile
 }
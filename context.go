@@ -0,0 +1,54 @@
+package gomake
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Action is a function to evaluate a rule with the context it's evaluated in.
+type Action func(ctx *Context) error
+
+// Context is the context in which a rule's Action is evaluated.
+type Context struct {
+	context.Context
+
+	// Rule is the rule being evaluated.
+	Rule *Rule
+	// Stdout is where the rule should write its output.
+	Stdout io.Writer
+	// Stderr is where the rule should write its errors.
+	Stderr io.Writer
+	// DryRun is whether the rule should only print what it would do. Commands
+	// run with Run, Output or Command are printed instead of run, but any
+	// other side effects must be guarded by the Action itself.
+	DryRun bool
+	// Silent is whether commands are run without printing them first.
+	Silent bool
+	// GracePeriod is how long commands are given to exit after being
+	// signalled when the Context is done, defaulting to DefaultGracePeriod.
+	GracePeriod time.Duration
+
+	// evaluation is the evaluation the rule is part of, if any, where the
+	// values of its dependencies are looked up.
+	evaluation *evaluation
+	// value is the value produced by the rule, if it's a ValueRule.
+	value any
+	// held are the resources held by the rule, which are released while it
+	// waits for the rules it needs.
+	held *heldResources
+}
+
+// Param returns the value of the parameter name of the rule, or "" if it
+// doesn't have one.
+func (c *Context) Param(name string) string {
+	return c.Rule.Params[name]
+}
+
+func (c *Context) gracePeriod() time.Duration {
+	if c.GracePeriod == 0 {
+		return DefaultGracePeriod
+	}
+
+	return c.GracePeriod
+}
@@ -0,0 +1,210 @@
+package gomake
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// CommandError is returned when a command fails to run or exits unsuccessfully.
+type CommandError struct {
+	// Command is the command line that failed.
+	Command string
+	// ExitCode is the exit code of the command, or -1 if it didn't exit on
+	// its own.
+	ExitCode int
+	// Err is the underlying error from running the command.
+	Err error
+}
+
+func (e *CommandError) Error() string {
+	if e.ExitCode >= 0 {
+		return fmt.Sprintf("%s: exit status %d", e.Command, e.ExitCode)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Command, e.Err)
+}
+
+// Unwrap returns the underlying error from running the command.
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Cmd is an external command run on behalf of a rule. Unlike an exec.Cmd, its
+// output goes to the rule's Context, it is run in its own process group which
+// is signalled when the Context is done, and it is only printed during a dry
+// run.
+type Cmd struct {
+	// Name is the name or path of the program to run.
+	Name string
+	// Args are the arguments to the program, not including its name.
+	Args []string
+	// Dir is the working directory of the command. If empty, the command runs
+	// in the current directory.
+	Dir string
+	// Env are environment variables in the form "key=value" to set on top of
+	// the environment of the current process.
+	Env []string
+
+	// script is the shell script run by the command, if it was created with
+	// Shell, which is shown instead of the shell's command line.
+	script string
+	ctx    *Context
+}
+
+// Command returns a Cmd to run the program name with args in ctx.
+func Command(ctx *Context, name string, args ...string) *Cmd {
+	return &Cmd{
+		Name: name,
+		Args: args,
+		ctx:  ctx,
+	}
+}
+
+// Shell returns a Cmd to run script with sh in ctx.
+func Shell(ctx *Context, script string) *Cmd {
+	cmd := Command(ctx, "sh", "-c", script)
+	cmd.script = script
+	return cmd
+}
+
+// Run runs the command in ctx and waits for it to finish.
+func Run(ctx *Context, name string, args ...string) error {
+	return Command(ctx, name, args...).Run()
+}
+
+// Output runs the command in ctx and returns its output with surrounding
+// whitespace trimmed.
+func Output(ctx *Context, name string, args ...string) (string, error) {
+	return Command(ctx, name, args...).Output()
+}
+
+// Run runs the command and waits for it to finish.
+func (c *Cmd) Run() error {
+	return c.run(c.ctx.Stdout)
+}
+
+// Output runs the command and returns its output with surrounding whitespace
+// trimmed. Its errors are still written to the rule's Context.
+func (c *Cmd) Output() (string, error) {
+	var buf bytes.Buffer
+	err := c.run(&buf)
+	return strings.TrimSpace(buf.String()), err
+}
+
+// String returns the command line as it would be typed into a shell.
+func (c *Cmd) String() string {
+	var words []string
+	if c.Dir != "" {
+		words = append(words, "cd", quote(c.Dir), "&&")
+	}
+
+	// Only quote values so that variables are still assigned
+	for _, env := range c.Env {
+		name, value, ok := strings.Cut(env, "=")
+		if !ok {
+			words = append(words, quote(env))
+			continue
+		}
+
+		words = append(words, name+"="+quote(value))
+	}
+
+	if c.script != "" {
+		return strings.Join(append(words, c.script), " ")
+	}
+
+	words = append(words, quote(c.Name))
+	for _, arg := range c.Args {
+		words = append(words, quote(arg))
+	}
+
+	return strings.Join(words, " ")
+}
+
+func (c *Cmd) run(stdout io.Writer) error {
+	if !c.ctx.Silent || c.ctx.DryRun {
+		fmt.Fprintf(c.ctx.Stdout, "+ %s\n", c)
+	}
+
+	if c.ctx.DryRun {
+		return nil
+	}
+
+	cmd := exec.Command(c.Name, c.Args...)
+	cmd.Dir = c.Dir
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = c.ctx.Stderr
+	setProcessGroup(cmd)
+
+	err := c.ctx.Err()
+	if err == nil {
+		err = c.start(cmd)
+	}
+	if err != nil {
+		return &CommandError{
+			Command:  c.String(),
+			ExitCode: exitCode(err),
+			Err:      err,
+		}
+	}
+
+	return nil
+}
+
+// start starts cmd and waits for it to finish. If the Context is done before
+// then, the command's process group is signalled and given a grace period to
+// exit before being killed.
+func (c *Cmd) start(cmd *exec.Cmd) error {
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	exited := make(chan struct{})
+	stop := context.AfterFunc(c.ctx, func() {
+		signalProcessGroup(cmd.Process, terminateSignal(c.ctx))
+
+		select {
+		case <-exited:
+		case <-time.After(c.ctx.gracePeriod()):
+			signalProcessGroup(cmd.Process, os.Kill)
+		}
+	})
+	defer stop()
+
+	err = cmd.Wait()
+	close(exited)
+	return err
+}
+
+// exitCode returns the exit code of the command that ran with err, or -1 if
+// it didn't exit on its own.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	return -1
+}
+
+// quote quotes word in single quotes if it has characters that a shell would
+// interpret. Each single quote in word ends the quoted string, is written
+// escaped with a backslash and then starts a new quoted string.
+func quote(word string) string {
+	if word != "" && !strings.ContainsAny(word, " \t\n\"'\\$`|&;<>()*?[]{}~#!") {
+		return word
+	}
+
+	return "'" + strings.ReplaceAll(word, "'", `'\''`) + "'"
+}
@@ -0,0 +1,188 @@
+package gomake
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newExecTestContext(buf *bytes.Buffer) *Context {
+	return &Context{
+		Context: context.Background(),
+		Stdout:  buf,
+		Stderr:  buf,
+	}
+}
+
+func TestRun(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := newExecTestContext(&buf)
+
+	err := Run(ctx, "echo", "hello world")
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	expected := "+ echo 'hello world'\nhello world\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, buf.String())
+	}
+}
+
+func TestRunSilent(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := newExecTestContext(&buf)
+	ctx.Silent = true
+
+	err := Run(ctx, "echo", "hello")
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	if buf.String() != "hello\n" {
+		t.Errorf("Expected only command output but got %q", buf.String())
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := newExecTestContext(&buf)
+	ctx.DryRun = true
+
+	err := Run(ctx, "false")
+	if err != nil {
+		t.Errorf("Expected command to not run but got %s", err)
+	}
+
+	if buf.String() != "+ false\n" {
+		t.Errorf("Expected command to be printed but got %q", buf.String())
+	}
+}
+
+func TestRunErr(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := newExecTestContext(&buf)
+
+	err := Run(ctx, "sh", "-c", "exit 3")
+	var cmdErr *CommandError
+	if !errors.As(err, &cmdErr) {
+		t.Fatalf("Expected CommandError but got %v", err)
+	}
+
+	if cmdErr.ExitCode != 3 {
+		t.Errorf("Expected exit code 3 but got %d", cmdErr.ExitCode)
+	}
+
+	expected := "sh -c 'exit 3': exit status 3"
+	if err.Error() != expected {
+		t.Errorf("Expected %q but got %q", expected, err)
+	}
+}
+
+func TestCommandDirEnv(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := newExecTestContext(&buf)
+	ctx.Silent = true
+
+	cmd := Command(ctx, "sh", "-c", "echo $GOMAKE_TEST; pwd")
+	cmd.Dir = "/"
+	cmd.Env = []string{"GOMAKE_TEST=value"}
+
+	output, err := cmd.Output()
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	expected := "value\n/"
+	if output != expected {
+		t.Errorf("Expected %q but got %q", expected, output)
+	}
+
+	if !strings.HasPrefix(cmd.String(), "cd / && GOMAKE_TEST=value sh") {
+		t.Errorf("Expected dir and env in command line but got %s", cmd)
+	}
+}
+
+func TestRunCancel(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := newExecTestContext(&buf)
+
+	parent, cancel := context.WithCancel(context.Background())
+	ctx.Context = parent
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	begin := time.Now()
+	err := Run(ctx, "sleep", "10")
+	if err == nil {
+		t.Errorf("Expected err from cancelled command")
+	}
+
+	if time.Since(begin) > 5*time.Second {
+		t.Errorf("Expected command to be killed when cancelled")
+	}
+}
+
+func TestActionRun(t *testing.T) {
+	var buf bytes.Buffer
+	rule := NewRule("echo", nil, nil)
+	rule.Action = func(ctx *Context) error {
+		output, err := Output(ctx, "echo", "hello")
+		if err != nil {
+			return err
+		}
+
+		return Run(ctx, "echo", output, "world")
+	}
+
+	evaluator := &Evaluator{
+		Stdout: &buf,
+		Stderr: &buf,
+	}
+
+	err := HandleResults(evaluator.Evaluate(rule))
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	expected := "[echo] + echo hello\n[echo] + echo hello world\n[echo] hello world\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, buf.String())
+	}
+}
+
+func TestShell(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := newExecTestContext(&buf)
+
+	err := Shell(ctx, "echo hello | tr a-z A-Z").Run()
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	expected := "+ echo hello | tr a-z A-Z\nHELLO\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, buf.String())
+	}
+}
+
+func TestQuote(t *testing.T) {
+	words := []string{"plain", "", "hello world", "it's", `"$HOME"`, "a\\b", "'';`ls`"}
+	for _, word := range words {
+		var buf bytes.Buffer
+		ctx := newExecTestContext(&buf)
+		ctx.Silent = true
+
+		// The shell reads back the original word
+		output, err := Command(ctx, "sh", "-c", "printf %s "+quote(word)).Output()
+		if err != nil {
+			t.Fatalf("Unexpected err: %s", err)
+		}
+
+		if output != word {
+			t.Errorf("Expected %q but got %q", word, output)
+		}
+	}
+}
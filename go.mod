@@ -0,0 +1,3 @@
+module github.com/hinshun/gomake
+
+go 1.21
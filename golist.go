@@ -0,0 +1,228 @@
+package gomake
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// goListFields are the fields of go list's JSON output that are decoded.
+var goListFields = []string{
+	"Dir", "ImportPath", "Standard", "Module",
+	"GoFiles", "CgoFiles", "CFiles", "CXXFiles", "MFiles", "HFiles", "FFiles",
+	"SFiles", "SwigFiles", "SwigCXXFiles", "SysoFiles", "EmbedFiles",
+}
+
+// goPackage is a package in go list's JSON output.
+type goPackage struct {
+	Dir        string
+	ImportPath string
+	Standard   bool
+	Module     *goModule
+
+	GoFiles      []string
+	CgoFiles     []string
+	CFiles       []string
+	CXXFiles     []string
+	MFiles       []string
+	HFiles       []string
+	FFiles       []string
+	SFiles       []string
+	SwigFiles    []string
+	SwigCXXFiles []string
+	SysoFiles    []string
+	EmbedFiles   []string
+}
+
+// goModule is the module of a package in go list's JSON output.
+type goModule struct {
+	Main    bool
+	GoMod   string
+	Replace *goModule
+	Version string
+}
+
+// files returns the source files of the package that are part of the build.
+func (p *goPackage) files() []string {
+	var files []string
+	for _, names := range [][]string{
+		p.GoFiles, p.CgoFiles, p.CFiles, p.CXXFiles, p.MFiles, p.HFiles, p.FFiles,
+		p.SFiles, p.SwigFiles, p.SwigCXXFiles, p.SysoFiles, p.EmbedFiles,
+	} {
+		for _, name := range names {
+			files = append(files, filepath.Join(p.Dir, name))
+		}
+	}
+
+	return files
+}
+
+// cached returns whether the package is in the module cache, where its files
+// can't change without its version in go.mod changing.
+func (p *goPackage) cached() bool {
+	module := p.Module
+	if module == nil || module.Main {
+		return false
+	}
+
+	if module.Replace != nil {
+		// Replaced by a local directory
+		if module.Replace.Version == "" {
+			return false
+		}
+		module = module.Replace
+	}
+
+	return module.Version != ""
+}
+
+// GoPackages discovers the files Go packages are built from with go list, to
+// use as the Inputs of rules that build them. The files are listed once for
+// every set of patterns and cached, so rules building the same packages don't
+// run go list again.
+type GoPackages struct {
+	// Dir is the directory go list is run in, defaulting to the current
+	// directory.
+	Dir string
+	// Tags are the build tags files are selected with.
+	Tags []string
+	// GOOS and GOARCH are the platform files are selected for, defaulting to
+	// the environment of the go command.
+	GOOS   string
+	GOARCH string
+
+	mu    sync.Mutex
+	cache map[string][]string
+}
+
+var defaultGoPackages = &GoPackages{}
+
+// GoInputs returns the files that the packages matching patterns and every
+// package they import are built from for the current platform, along with the
+// go.mod and go.sum files of their modules. See GoPackages.Inputs.
+func GoInputs(patterns ...string) ([]string, error) {
+	return defaultGoPackages.Inputs(patterns...)
+}
+
+// Inputs returns the files that the packages matching patterns and every
+// package they import are built from, selected by the build tags and platform,
+// along with the go.mod and go.sum files of their modules. Standard library
+// packages and packages in the module cache are left out, as they only change
+// with the Go version or go.mod. Files inside the current directory are
+// returned relative to it.
+//
+// The files are listed when Inputs is first called, so files added to the
+// packages afterwards aren't included.
+func (p *GoPackages) Inputs(patterns ...string) ([]string, error) {
+	key := strings.Join(patterns, "\x00")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	inputs, ok := p.cache[key]
+	if !ok {
+		var err error
+		inputs, err = p.list(patterns)
+		if err != nil {
+			return nil, err
+		}
+
+		if p.cache == nil {
+			p.cache = make(map[string][]string)
+		}
+		p.cache[key] = inputs
+	}
+
+	return append([]string{}, inputs...), nil
+}
+
+// list runs go list on patterns and returns the sorted files of the packages.
+func (p *GoPackages) list(patterns []string) ([]string, error) {
+	args := []string{"list", "-deps", "-json=" + strings.Join(goListFields, ",")}
+	if len(p.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(p.Tags, ","))
+	}
+	args = append(args, "--")
+	args = append(args, patterns...)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("go", args...)
+	cmd.Dir = p.Dir
+	cmd.Env = os.Environ()
+	if p.GOOS != "" {
+		cmd.Env = append(cmd.Env, "GOOS="+p.GOOS)
+	}
+	if p.GOARCH != "" {
+		cmd.Env = append(cmd.Env, "GOARCH="+p.GOARCH)
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("go list %s: %s", strings.Join(patterns, " "), msg)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	add := func(path string) {
+		rel, err := filepath.Rel(wd, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			path = rel
+		}
+		seen[path] = true
+	}
+
+	decoder := json.NewDecoder(&stdout)
+	for {
+		var pkg goPackage
+		err = decoder.Decode(&pkg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if pkg.Standard || pkg.cached() {
+			continue
+		}
+
+		for _, file := range pkg.files() {
+			add(file)
+		}
+
+		if pkg.Module != nil && pkg.Module.GoMod != "" {
+			add(pkg.Module.GoMod)
+
+			sum := filepath.Join(filepath.Dir(pkg.Module.GoMod), "go.sum")
+			_, err = os.Stat(sum)
+			if err == nil {
+				add(sum)
+			}
+		}
+	}
+
+	inputs := make([]string, 0, len(seen))
+	for path := range seen {
+		inputs = append(inputs, path)
+	}
+	sort.Strings(inputs)
+
+	return inputs, nil
+}
@@ -0,0 +1,121 @@
+package gomake
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeGoListModule(t *testing.T) {
+	files := map[string]string{
+		"go.mod":           "module example.com/golist\n\ngo 1.21\n",
+		"main.go":          "package main\n\nimport _ \"example.com/golist/lib\"\n\nfunc main() {}\n",
+		"main_test.go":     "package main\n",
+		"lib/lib.go":       "package lib\n\nimport _ \"fmt\"\n",
+		"lib/lib_tag.go":   "//go:build integration\n\npackage lib\n",
+		"lib/lib_plan9.go": "package lib\n",
+		"other/other.go":   "package other\n",
+	}
+
+	for path, src := range files {
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatalf("Unexpected err: %s", err)
+		}
+
+		err = os.WriteFile(path, []byte(src), 0644)
+		if err != nil {
+			t.Fatalf("Unexpected err: %s", err)
+		}
+	}
+}
+
+func TestGoPackagesInputs(t *testing.T) {
+	chdir(t, t.TempDir())
+	t.Setenv("GO111MODULE", "on")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	writeGoListModule(t)
+
+	for _, test := range []struct {
+		name     string
+		packages *GoPackages
+		expected []string
+	}{
+		{
+			name:     "default",
+			packages: &GoPackages{},
+			expected: []string{"go.mod", "lib/lib.go", "main.go"},
+		},
+		{
+			name:     "tags",
+			packages: &GoPackages{Tags: []string{"integration"}},
+			expected: []string{"go.mod", "lib/lib.go", "lib/lib_tag.go", "main.go"},
+		},
+		{
+			name:     "platform",
+			packages: &GoPackages{GOOS: "plan9", GOARCH: "amd64"},
+			expected: []string{"go.mod", "lib/lib.go", "lib/lib_plan9.go", "main.go"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			inputs, err := test.packages.Inputs(".")
+			if err != nil {
+				t.Fatalf("Unexpected err: %s", err)
+			}
+
+			for i := range test.expected {
+				test.expected[i] = filepath.FromSlash(test.expected[i])
+			}
+			if !reflect.DeepEqual(inputs, test.expected) {
+				t.Errorf("Expected %v but got %v", test.expected, inputs)
+			}
+		})
+	}
+}
+
+func TestGoPackagesCache(t *testing.T) {
+	chdir(t, t.TempDir())
+	t.Setenv("GO111MODULE", "on")
+	t.Setenv("GOFLAGS", "-mod=mod")
+	writeGoListModule(t)
+
+	packages := &GoPackages{}
+	expected, err := packages.Inputs("./lib")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	// Files added after listing aren't picked up by the cached inputs
+	err = os.WriteFile(filepath.Join("lib", "new.go"), []byte("package lib\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	inputs, err := packages.Inputs("./lib")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+	if !reflect.DeepEqual(inputs, expected) {
+		t.Errorf("Expected %v but got %v", expected, inputs)
+	}
+
+	inputs, err = packages.Inputs("./lib", "./other")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+	if len(inputs) != len(expected)+2 {
+		t.Errorf("Expected new.go and other.go to be listed but got %v", inputs)
+	}
+}
+
+func TestGoPackagesError(t *testing.T) {
+	chdir(t, t.TempDir())
+	t.Setenv("GO111MODULE", "on")
+	writeGoListModule(t)
+
+	_, err := GoInputs("./missing")
+	if err == nil {
+		t.Errorf("Expected err but got nil")
+	}
+}
@@ -9,9 +9,7 @@ itself:
 	package main
 
 	import (
-		"fmt"
 		"os"
-		"os/exec"
 
 		"github.com/hinshun/gomake"
 	)
@@ -19,28 +17,49 @@ itself:
 	func main() {
 		gomakefile := gomake.NewGomakefile()
 
-		rebuild := gomakefile.AddRule("gomake", nil, func() error {
-			cmd := exec.Command("go", "build")
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-
-			return build.Run()
+		rebuild := gomakefile.AddAction("gomake", nil, func(ctx *gomake.Context) error {
+			return gomake.Run(ctx, "go", "build")
 		})
 
-		// Sets the default target
-		gomakefile.Targets[""] = rebuild
+		// Sets the default target, also made as "build"
+		gomakefile.SetDefault(rebuild)
+		gomakefile.AddAlias("build", rebuild)
 
-		err := gomake.Gomake(gomakefile).Run(os.Args)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(-1)
-		}
+		gomake.Gomake(gomakefile).RunAndExit(os.Args)
 	}
+
+The process exits with 0 on success, 1 if a rule fails, 2 on bad usage such
+as an unknown target, 3 if the dependency graph has a cycle and 130 when
+interrupted.
+
+Calling Bootstrap with the directory of the main package first, before making
+anything, rebuilds the binary when the Gomakefile changes and runs the rebuilt
+binary in its place, so the rules being made are never stale:
+
+	err := gomake.Bootstrap(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bootstrap: %s\n", err)
+	}
+
+Actions run commands with Run, Output and Command, which write to the rule's
+own output, are killed if the evaluation is cancelled and are only printed
+during a dry run.
 */
 package gomake
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
 	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/hinshun/gomake/pkg/cli"
 )
@@ -50,42 +69,409 @@ const (
 	Version = "0.1.0"
 )
 
+var (
+	// TimingsFlag is the flag to display a summary of rule timings after a run.
+	TimingsFlag = &cli.Flag{
+		Name:        "timings",
+		Description: "print a summary of rule timings to stderr",
+	}
+
+	// OutputFlag is the flag to choose how the output of rules is shown.
+	OutputFlag = &cli.Flag{
+		Name:        "output",
+		Description: "show rule output line by line (prefix), per rule (buffered) or only on failure (failed)",
+		TakesValue:  true,
+		Default:     string(OutputPrefix),
+	}
+
+	// FormatFlag is the flag to choose the format results are reported in.
+	FormatFlag = &cli.Flag{
+		Name:        "format",
+		Description: "report failed targets as text or every target as json",
+		TakesValue:  true,
+		Default:     string(FormatText),
+	}
+
+	// TimeoutFlag is the flag to limit how long each rule may take.
+	TimeoutFlag = &cli.Flag{
+		Name:        "timeout",
+		Description: "time limit for rules without their own, such as 10m",
+		TakesValue:  true,
+	}
+
+	// JobsFlag is the flag to limit how many rules evaluate at once.
+	JobsFlag = &cli.Flag{
+		Name:        "jobs",
+		Aliases:     []string{"j"},
+		Description: "most rules to evaluate at once, unlimited if 0",
+		TakesValue:  true,
+		Default:     "0",
+	}
+
+	// WatchFlag is the flag to make the target again whenever its inputs
+	// change.
+	WatchFlag = &cli.Flag{
+		Name:        "watch",
+		Aliases:     []string{"w"},
+		Description: "make the target again whenever its inputs change",
+	}
+
+	// GraphFlag is the flag to print the target's dependency graph instead of
+	// making it.
+	GraphFlag = &cli.Flag{
+		Name:        "graph",
+		Description: "print the dependency graph in DOT instead of making the target",
+	}
+
+	// TagFlag is the flag to make every target with any of the given tags.
+	TagFlag = &cli.Flag{
+		Name:        "tag",
+		Description: "make every target with any of these comma-separated tags",
+		TakesValue:  true,
+	}
+
+	// ExcludeTagFlag is the flag to leave out targets with any of the given
+	// tags when selecting targets by tag.
+	ExcludeTagFlag = &cli.Flag{
+		Name:        "exclude-tag",
+		Description: "leave out targets with any of these comma-separated tags",
+		TakesValue:  true,
+	}
+
+	// ListFlag is the flag to list the targets selected by tag instead of
+	// making them.
+	ListFlag = &cli.Flag{
+		Name:        "list",
+		Aliases:     []string{"l"},
+		Description: "list targets with their aliases and tags instead of making them",
+	}
+
+	// MakefileFlag is the flag to choose the Makefile to import or generate.
+	MakefileFlag = &cli.Flag{
+		Name:        "makefile",
+		Description: "path of the Makefile",
+		TakesValue:  true,
+		Default:     "Makefile",
+	}
+
+	// PackageFlag is the flag to choose the package of generated Go source.
+	PackageFlag = &cli.Flag{
+		Name:        "package",
+		Description: "package of the generated Go source",
+		TakesValue:  true,
+		Default:     "main",
+	}
+
+	// BinaryFlag is the flag to choose the gomake binary a generated Makefile
+	// delegates to.
+	BinaryFlag = &cli.Flag{
+		Name:        "binary",
+		Description: "path of the gomake binary the Makefile runs",
+		TakesValue:  true,
+		Default:     "./gomake",
+	}
+
+	// CheckFlag is the flag to check that a generated file is up to date
+	// instead of writing it.
+	CheckFlag = &cli.Flag{
+		Name:        "check",
+		Description: "fail if the file is out of date instead of writing it",
+	}
+
+	// DryRunFlag is the flag to print commands instead of running them.
+	DryRunFlag = &cli.Flag{
+		Name:        "dry-run",
+		Aliases:     []string{"n"},
+		Description: "print commands instead of running them",
+	}
+
+	// SilentFlag is the flag to run commands without printing them first.
+	SilentFlag = &cli.Flag{
+		Name:        "silent",
+		Aliases:     []string{"s"},
+		Description: "don't print commands before running them",
+	}
+)
+
 // Gomake creates a cli app for the given Gomakefile.
 func Gomake(gomakefile *Gomakefile) *cli.App {
 	app := &cli.App{
 		Name:    "gomake - Makefile for gophers",
 		Version: Version,
 		Action: func(ctx *cli.Context) error {
-			_, ok := gomakefile.Targets[""]
+			if ctx.IsSet(ListFlag.Name) || ctx.IsSet(TagFlag.Name) || ctx.IsSet(ExcludeTagFlag.Name) {
+				return makeSelected(ctx, gomakefile)
+			}
+
+			rule, ok := gomakefile.Targets[""]
 			if !ok {
 				return nil
 			}
 
-			results := gomakefile.Make("")
-			return HandleResults(results)
+			return makeRules(ctx, gomakefile, rule)
 		},
+		Flags: cli.Flags{TimingsFlag, OutputFlag, FormatFlag, JobsFlag, TimeoutFlag, WatchFlag, GraphFlag, TagFlag, ExcludeTagFlag, ListFlag, DryRunFlag, SilentFlag},
 	}
 
-	for gomakeTarget, rule := range gomakefile.Targets {
-		// Skip default
-		if gomakeTarget == "" {
+	// Create one command for each rule, with its other names as aliases
+	commands := make(map[*Rule]bool)
+	for _, rule := range gomakefile.Targets {
+		if commands[rule] {
+			continue
+		}
+		commands[rule] = true
+
+		// Skip default unless it has a name too
+		names := gomakefile.Names(rule)
+		if len(names) == 0 {
 			continue
 		}
 
 		// Create closure around target for command
-		target := gomakeTarget
+		target := names[0]
 		command := &cli.Command{
 			Name:        target,
+			Aliases:     names[1:],
 			Description: rule.Description,
+			Category:    Namespace(target),
 			Action: func(ctx *cli.Context) error {
-				results := gomakefile.Make(target)
-				return HandleResults(results)
+				return makeTarget(ctx, gomakefile, target)
 			},
 		}
 
 		app.Commands = append(app.Commands, command)
 	}
 
+	// Built in commands unless the Gomakefile has targets with their names
+	builtins := []*cli.Command{
+		{
+			Name:        "import-makefile",
+			Description: "print a Gomakefile in Go translated from a Makefile",
+			Action:      importMakefile,
+			Flags:       cli.Flags{MakefileFlag, PackageFlag},
+		},
+		{
+			Name:        "generate-makefile",
+			Description: "write a Makefile that delegates every target to gomake",
+			Action: func(ctx *cli.Context) error {
+				return generateMakefile(ctx, gomakefile)
+			},
+			Flags: cli.Flags{MakefileFlag, BinaryFlag, CheckFlag},
+		},
+	}
+	for _, command := range builtins {
+		_, ok := gomakefile.Targets[command.Name]
+		if !ok {
+			app.Commands = append(app.Commands, command)
+		}
+	}
+
 	sort.Sort(app.Commands)
 	return app
 }
+
+// importMakefile prints the Go source translated from a Makefile, and warns
+// about the constructs that couldn't be translated.
+func importMakefile(ctx *cli.Context) error {
+	makefile, err := ParseMakefileFile(ctx.String(MakefileFlag.Name))
+	if err != nil {
+		return cli.Exit(err, cli.ExitFailure)
+	}
+
+	for _, warning := range makefile.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+
+	return exitError(makefile.WriteGo(os.Stdout, ctx.String(PackageFlag.Name)))
+}
+
+// makeTarget makes the target with an Evaluator configured by the flags set in
+// ctx.
+func makeTarget(ctx *cli.Context, gomakefile *Gomakefile, target string) error {
+	if ctx.IsSet(TagFlag.Name) || ctx.IsSet(ExcludeTagFlag.Name) {
+		return cli.Exit(errors.New("targets can't be selected by tag and by name"), cli.ExitUsage)
+	}
+
+	rule, ok := gomakefile.Targets[target]
+	if !ok {
+		return exitError(&TargetError{Target: target, Err: ErrNoSuchTarget})
+	}
+
+	return makeRules(ctx, gomakefile, rule)
+}
+
+// makeSelected lists or makes the targets selected by tag.
+func makeSelected(ctx *cli.Context, gomakefile *Gomakefile) error {
+	rules := gomakefile.Select(splitList(ctx.String(TagFlag.Name)), splitList(ctx.String(ExcludeTagFlag.Name)))
+	if ctx.IsSet(ListFlag.Name) {
+		return listTargets(os.Stdout, gomakefile, rules)
+	}
+
+	if len(rules) == 0 {
+		return cli.Exit(errors.New("no targets match the tags"), cli.ExitUsage)
+	}
+
+	return makeRules(ctx, gomakefile, rules...)
+}
+
+// makeRules makes rules and their dependencies with the options in ctx.
+func makeRules(ctx *cli.Context, gomakefile *Gomakefile, rules ...*Rule) error {
+	if ctx.IsSet(GraphFlag.Name) {
+		return exitError(WriteGraph(os.Stdout, rules...))
+	}
+
+	output, err := ParseOutputMode(ctx.String(OutputFlag.Name))
+	if err != nil {
+		return cli.Exit(err, cli.ExitUsage)
+	}
+
+	format, err := ParseFormat(ctx.String(FormatFlag.Name))
+	if err != nil {
+		return cli.Exit(err, cli.ExitUsage)
+	}
+
+	var timeout time.Duration
+	if ctx.IsSet(TimeoutFlag.Name) {
+		timeout, err = time.ParseDuration(ctx.String(TimeoutFlag.Name))
+		if err != nil {
+			return cli.Exit(err, cli.ExitUsage)
+		}
+	}
+
+	jobs, err := strconv.Atoi(ctx.String(JobsFlag.Name))
+	if err != nil || jobs < 0 {
+		return cli.Exit(fmt.Errorf("invalid number of jobs %q", ctx.String(JobsFlag.Name)), cli.ExitUsage)
+	}
+
+	evaluator := &Evaluator{
+		Output:  output,
+		DryRun:  ctx.IsSet(DryRunFlag.Name),
+		Silent:  ctx.IsSet(SilentFlag.Name),
+		Timeout: timeout,
+		Jobs:    jobs,
+	}
+
+	var timings *Timings
+	if ctx.IsSet(TimingsFlag.Name) {
+		timings = NewTimings()
+		evaluator.Observers = append(evaluator.Observers, timings)
+	}
+
+	// Cancel the evaluation on an interrupt so that commands can be stopped
+	// instead of left running
+	interruptCtx, stop := notifyContext(context.Background())
+	defer stop()
+
+	if ctx.IsSet(WatchFlag.Name) {
+		return watchRules(interruptCtx, evaluator, rules)
+	}
+
+	results := gomakefile.MakeRules(interruptCtx, evaluator, rules...)
+
+	// Failures are already in the text of the returned error
+	if format != FormatText {
+		err = results.Write(os.Stdout, format)
+		if err != nil {
+			return cli.Exit(err, cli.ExitInternal)
+		}
+	}
+
+	// Timings go to stderr so they don't mix with results written to stdout
+	if timings != nil {
+		err = timings.WriteReport(os.Stderr)
+		if err != nil {
+			return cli.Exit(err, cli.ExitInternal)
+		}
+	}
+
+	cause := context.Cause(interruptCtx)
+	if errors.Is(cause, ErrInterrupted) {
+		return cli.Exit(cause, cli.ExitInterrupted)
+	}
+
+	return exitError(results.Err())
+}
+
+// watchRules makes rules whenever their inputs change until interrupted.
+func watchRules(ctx context.Context, evaluator *Evaluator, rules []*Rule) error {
+	watcher := &Watcher{
+		Evaluator: evaluator,
+	}
+
+	err := watcher.Watch(ctx, rules...)
+	if errors.Is(err, ErrInterrupted) {
+		return cli.Exit(err, cli.ExitInterrupted)
+	}
+
+	return exitError(err)
+}
+
+// generateMakefile writes a Makefile delegating to gomake, or checks that it's
+// up to date.
+func generateMakefile(ctx *cli.Context, gomakefile *Gomakefile) error {
+	path := ctx.String(MakefileFlag.Name)
+
+	var buf bytes.Buffer
+	err := gomakefile.WriteMakefile(&buf, ctx.String(BinaryFlag.Name))
+	if err != nil {
+		return cli.Exit(err, cli.ExitFailure)
+	}
+
+	if !ctx.IsSet(CheckFlag.Name) {
+		return exitError(os.WriteFile(path, buf.Bytes(), 0644))
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return cli.Exit(err, cli.ExitFailure)
+	}
+
+	if !bytes.Equal(current, buf.Bytes()) {
+		return cli.Exit(fmt.Errorf("%s is out of date, run gomake generate-makefile", path), cli.ExitFailure)
+	}
+
+	return nil
+}
+
+// listTargets writes the names, tags and descriptions of rules to w.
+func listTargets(w io.Writer, gomakefile *Gomakefile, rules []*Rule) error {
+	writer := tabwriter.NewWriter(w, 1, 8, 2, ' ', 0)
+	fmt.Fprintf(writer, "TARGET\tTAGS\tDESCRIPTION\n")
+	for _, rule := range rules {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", strings.Join(gomakefile.Names(rule), ", "), strings.Join(rule.Tags, ","), rule.Description)
+	}
+
+	return writer.Flush()
+}
+
+// splitList returns the comma-separated values in list.
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// exitError returns err with the exit code for its class of failure: usage
+// errors for unknown targets, internal errors for a bad dependency graph and
+// failures for everything else.
+func exitError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var cycleErr *CycleError
+	switch {
+	case errors.Is(err, ErrNoSuchTarget):
+		return cli.Exit(err, cli.ExitUsage)
+	case errors.As(err, &cycleErr):
+		return cli.Exit(err, cli.ExitInternal)
+	}
+
+	return cli.Exit(err, cli.ExitFailure)
+}
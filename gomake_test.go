@@ -1,9 +1,12 @@
 package gomake
 
 import (
+	"errors"
 	"os"
 	"os/exec"
 	"testing"
+
+	"github.com/hinshun/gomake/pkg/cli"
 )
 
 func TestGomake(t *testing.T) {
@@ -22,3 +25,62 @@ func TestGomake(t *testing.T) {
 		t.Errorf("Unexpected err %s", err)
 	}
 }
+
+func TestGomakeExitCodes(t *testing.T) {
+	gomakefile := NewGomakefile()
+
+	gomakefile.AddRule("fail", nil, func() error {
+		return errors.New("intentional")
+	})
+
+	cycle := gomakefile.AddRule("cycle", nil, func() error {
+		return nil
+	})
+	cycle.Dependencies = []*Rule{cycle}
+
+	for _, test := range []struct {
+		args     []string
+		expected int
+	}{
+		{[]string{"gomake"}, cli.ExitSuccess},
+		{[]string{"gomake", "fail"}, cli.ExitFailure},
+		{[]string{"gomake", "unknown"}, cli.ExitUsage},
+		{[]string{"gomake", "--output=unknown", "fail"}, cli.ExitUsage},
+		{[]string{"gomake", "cycle"}, cli.ExitInternal},
+	} {
+		err := Gomake(gomakefile).Run(test.args)
+		actual := cli.ExitCode(err)
+		if actual != test.expected {
+			t.Errorf("Expected exit code %d for %v but got %d", test.expected, test.args, actual)
+		}
+	}
+}
+
+func TestGomakeTags(t *testing.T) {
+	gomakefile := NewGomakefile()
+
+	var made []string
+	for _, target := range []string{"vet", "lint", "integration"} {
+		target := target
+		rule := gomakefile.AddRule(target, nil, func() error {
+			made = append(made, target)
+			return nil
+		})
+		rule.Tags = []string{"check"}
+	}
+	gomakefile.Targets["integration"].Tags = append(gomakefile.Targets["integration"].Tags, "slow")
+
+	err := Gomake(gomakefile).Run([]string{"gomake", "--tag=check", "--exclude-tag=slow", "--jobs=1"})
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	if len(made) != 2 {
+		t.Errorf("Expected lint and vet to be made but got %s", made)
+	}
+
+	err = Gomake(gomakefile).Run([]string{"gomake", "--tag=unknown"})
+	if cli.ExitCode(err) != cli.ExitUsage {
+		t.Errorf("Expected exit code %d but got %d", cli.ExitUsage, cli.ExitCode(err))
+	}
+}
@@ -1,22 +1,46 @@
 package gomake
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NamespaceSeparator separates the namespace of an included target from its
+// name, as in "api:build".
+const NamespaceSeparator = ":"
 
 var (
 	// ErrNoSuchTarget is returned if a Gomakefile is ran with an unknown target.
 	ErrNoSuchTarget = errors.New("no such target")
+
+	// ErrDuplicateTarget is returned if a Gomakefile includes a target or
+	// pool with the same name as one it already has.
+	ErrDuplicateTarget = errors.New("duplicate target")
+
+	// ErrIncluded is returned if a Gomakefile includes a rule or pool that
+	// has already been included into a namespace.
+	ErrIncluded = errors.New("already included")
 )
 
 // Gomakefile is a Makefile representation for gophers.
 type Gomakefile struct {
 	// Targets is the map of target names to Rules.
 	Targets map[string]*Rule
+	// Pools is the map of pool names to the Pools rules can require.
+	Pools map[string]*Pool
+	// Finalizers are rules evaluated after every target is made, whether it
+	// succeeds, fails or is interrupted.
+	Finalizers []*Rule
 }
 
 // NewGomakefile initializes a Gomakefile that can rebuild itself.
 func NewGomakefile() *Gomakefile {
 	return &Gomakefile{
 		Targets: make(map[string]*Rule),
+		Pools:   make(map[string]*Pool),
 	}
 }
 
@@ -27,14 +51,314 @@ func (g *Gomakefile) AddRule(target string, dependencies []*Rule, evaluate func(
 	return rule
 }
 
+// AddAction creates a new rule evaluated by action and adds it to the
+// Gomakefile.
+func (g *Gomakefile) AddAction(target string, dependencies []*Rule, action Action) *Rule {
+	rule := NewRule(target, dependencies, nil)
+	rule.Action = action
+	g.Targets[target] = rule
+	return rule
+}
+
+// AddGroup creates a new group rule that depends on members and adds it to the
+// Gomakefile.
+func (g *Gomakefile) AddGroup(target string, members ...*Rule) *Rule {
+	rule := NewGroup(target, members...)
+	g.Targets[target] = rule
+	return rule
+}
+
+// AddPool creates a new pool with capacity units and adds it to the
+// Gomakefile.
+func (g *Gomakefile) AddPool(name string, capacity int) *Pool {
+	pool := NewPool(name, capacity)
+	g.Pools[name] = pool
+	return pool
+}
+
+// AddAlias makes rule available as alias too. It returns an error wrapping
+// ErrDuplicateTarget if alias is already a target, or ErrNoSuchTarget if rule
+// isn't in the Gomakefile.
+func (g *Gomakefile) AddAlias(alias string, rule *Rule) error {
+	if alias == "" {
+		return fmt.Errorf("invalid alias %q", alias)
+	}
+
+	_, ok := g.Targets[alias]
+	if ok {
+		return fmt.Errorf("%s: %w", alias, ErrDuplicateTarget)
+	}
+
+	if len(g.Names(rule)) == 0 {
+		return fmt.Errorf("%s: %w", rule.Target, ErrNoSuchTarget)
+	}
+
+	g.Targets[alias] = rule
+	return nil
+}
+
+// SetDefault sets the rule made when no target is given. It returns an error
+// wrapping ErrNoSuchTarget if rule isn't in the Gomakefile.
+func (g *Gomakefile) SetDefault(rule *Rule) error {
+	if rule == nil {
+		return fmt.Errorf("default: %w", ErrNoSuchTarget)
+	}
+
+	if len(g.Names(rule)) == 0 {
+		return fmt.Errorf("%s: %w", rule.Target, ErrNoSuchTarget)
+	}
+
+	g.Targets[""] = rule
+	return nil
+}
+
+// Names returns the names rule can be made as, with its canonical name first
+// followed by its aliases in order. The canonical name is the rule's target
+// if it's one of them.
+func (g *Gomakefile) Names(rule *Rule) []string {
+	var (
+		names     []string
+		canonical bool
+	)
+	for target, r := range g.Targets {
+		// The default target isn't a name
+		if r != rule || target == "" {
+			continue
+		}
+
+		if target == rule.Target {
+			canonical = true
+			continue
+		}
+
+		names = append(names, target)
+	}
+
+	sort.Strings(names)
+	if canonical {
+		names = append([]string{rule.Target}, names...)
+	}
+
+	return names
+}
+
+// Include moves the targets, pools and finalizers of other into the Gomakefile
+// under the namespace prefix, so that other's "build" target is made as
+// "prefix:build". The rules of other are moved rather than copied, so rules
+// in either Gomakefile can depend on each other, and their targets are
+// renamed to include the namespace. Rules that other's targets and finalizers
+// use without registering them are renamed too, so they don't collide with
+// rules of the same name in other namespaces. other is left empty.
+//
+// Nothing is moved if any name collides with one already in the Gomakefile, in
+// which case an error wrapping ErrDuplicateTarget is returned, or if any rule
+// or pool of other has already been included, in which case an error wrapping
+// ErrIncluded is returned.
+func (g *Gomakefile) Include(prefix string, other *Gomakefile) error {
+	if prefix == "" || strings.Contains(prefix, NamespaceSeparator) {
+		return fmt.Errorf("invalid namespace %q", prefix)
+	}
+
+	// Check every name first so that nothing is moved on collisions
+	var collisions, included []string
+	for target, rule := range other.Targets {
+		_, ok := g.Targets[namespaced(prefix, target)]
+		if ok {
+			collisions = append(collisions, namespaced(prefix, target))
+		}
+
+		if rule.included {
+			included = append(included, rule.Target)
+		}
+	}
+
+	for name, pool := range other.Pools {
+		_, ok := g.Pools[namespaced(prefix, name)]
+		if ok {
+			collisions = append(collisions, "pool "+namespaced(prefix, name))
+		}
+
+		if pool.included {
+			included = append(included, "pool "+pool.Name)
+		}
+	}
+
+	if len(included) > 0 {
+		sort.Strings(included)
+		return fmt.Errorf("%w: %s", ErrIncluded, strings.Join(included, ", "))
+	}
+
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return fmt.Errorf("%w: %s", ErrDuplicateTarget, strings.Join(collisions, ", "))
+	}
+
+	// Rename each rule once, even if it's under more than one target, along
+	// with the rules they use that aren't targets themselves
+	for _, rule := range g.reachable(other) {
+		rule.Target = namespaced(prefix, rule.Target)
+		rule.included = true
+	}
+
+	for target, rule := range other.Targets {
+		g.Targets[namespaced(prefix, target)] = rule
+	}
+
+	for name, pool := range other.Pools {
+		pool.Name = namespaced(prefix, pool.Name)
+		pool.included = true
+		g.Pools[namespaced(prefix, name)] = pool
+	}
+
+	g.Finalizers = append(g.Finalizers, other.Finalizers...)
+
+	// Leave nothing behind whose name doesn't match its key
+	other.Targets = make(map[string]*Rule)
+	other.Pools = make(map[string]*Pool)
+	other.Finalizers = nil
+	return nil
+}
+
+// reachable returns the rules of other's targets and finalizers and every
+// rule they depend on or are finalized by, except for rules of the Gomakefile
+// and rules that have already been included.
+func (g *Gomakefile) reachable(other *Gomakefile) []*Rule {
+	owned := make(map[*Rule]bool)
+	for _, rule := range g.Targets {
+		owned[rule] = true
+	}
+	for _, rule := range g.Finalizers {
+		owned[rule] = true
+	}
+
+	var (
+		rules   []*Rule
+		visited = make(map[*Rule]bool)
+		visit   func(rule *Rule)
+	)
+	visit = func(rule *Rule) {
+		if visited[rule] || owned[rule] || rule.included {
+			return
+		}
+		visited[rule] = true
+		rules = append(rules, rule)
+
+		for _, dependency := range rule.pulled() {
+			visit(dependency)
+		}
+		for _, dependency := range rule.OrderOnly {
+			visit(dependency)
+		}
+		for _, finalizer := range rule.Finalizers {
+			visit(finalizer)
+		}
+	}
+
+	for _, rule := range other.Targets {
+		visit(rule)
+	}
+	for _, rule := range other.Finalizers {
+		visit(rule)
+	}
+
+	return rules
+}
+
+// namespaced returns name in the namespace prefix. The default target of an
+// included Gomakefile is made as the namespace itself.
+func namespaced(prefix, name string) string {
+	if name == "" {
+		return prefix
+	}
+
+	return prefix + NamespaceSeparator + name
+}
+
+// Namespace returns the namespace of target, or "" if it isn't in one.
+func Namespace(target string) string {
+	i := strings.LastIndex(target, NamespaceSeparator)
+	if i < 0 {
+		return ""
+	}
+
+	return target[:i]
+}
+
+// Select returns the named rules tagged with any of tags, or every named rule
+// if tags is empty, except those tagged with any of excludeTags. The rules are
+// ordered by their canonical names. Dependencies of the selected rules are
+// still made with them even if they're excluded.
+func (g *Gomakefile) Select(tags, excludeTags []string) []*Rule {
+	selected := make(map[string]*Rule)
+	for _, rule := range g.Targets {
+		names := g.Names(rule)
+		if len(names) == 0 || !matchTags(rule, tags, excludeTags) {
+			continue
+		}
+
+		selected[names[0]] = rule
+	}
+
+	var names []string
+	for name := range selected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rules := make([]*Rule, len(names))
+	for i, name := range names {
+		rules[i] = selected[name]
+	}
+
+	return rules
+}
+
+// matchTags returns whether rule has any of tags, or tags is empty, and none
+// of excludeTags.
+func matchTags(rule *Rule, tags, excludeTags []string) bool {
+	for _, tag := range excludeTags {
+		if rule.HasTag(tag) {
+			return false
+		}
+	}
+
+	if len(tags) == 0 {
+		return true
+	}
+
+	for _, tag := range tags {
+		if rule.HasTag(tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Make makes the target rule and its dependencies.
-func (g *Gomakefile) Make(target string) map[string]error {
+func (g *Gomakefile) Make(target string) Results {
+	return g.MakeWith(context.Background(), new(Evaluator), target)
+}
+
+// MakeWith makes the target rule and its dependencies in ctx using evaluator.
+func (g *Gomakefile) MakeWith(ctx context.Context, evaluator *Evaluator, target string) Results {
 	rule, ok := g.Targets[target]
 	if !ok {
-		return map[string]error{
-			target: ErrNoSuchTarget,
+		return Results{
+			{
+				Target: target,
+				Status: StatusFailed,
+				Err:    ErrNoSuchTarget,
+			},
 		}
 	}
 
-	return Evaluate(rule)
+	return g.MakeRules(ctx, evaluator, rule)
+}
+
+// MakeRules makes rules and their dependencies in ctx using evaluator, with
+// the rules they share made once.
+func (g *Gomakefile) MakeRules(ctx context.Context, evaluator *Evaluator, rules ...*Rule) Results {
+	results := evaluator.EvaluateAll(ctx, rules...)
+	return append(results, evaluator.finalize(ctx, g.Finalizers, evaluator.newJobs())...)
 }
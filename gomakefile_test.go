@@ -26,7 +26,7 @@ func TestAddRule(t *testing.T) {
 func TestMake(t *testing.T) {
 	gomakefile := NewGomakefile()
 	results := gomakefile.Make("target")
-	if results["target"] == nil {
+	if results.Lookup("target").Err != ErrNoSuchTarget {
 		t.Errorf("Unknown target doesn't return error")
 	}
 
@@ -40,3 +40,246 @@ func TestMake(t *testing.T) {
 		t.Errorf("Expected %s but got %s", expected, actual)
 	}
 }
+
+func TestMakeFinalizers(t *testing.T) {
+	gomakefile := NewGomakefile()
+	gomakefile.AddRule("target", nil, func() error {
+		return errors.New("intentional")
+	})
+
+	finalized := false
+	gomakefile.Finalizers = []*Rule{
+		NewRule("finalizer", nil, func() error {
+			finalized = true
+			return nil
+		}),
+	}
+
+	results := gomakefile.Make("target")
+	if !finalized {
+		t.Errorf("Expected finalizer to run after target failed")
+	}
+
+	result := results.Lookup("finalizer")
+	if result == nil || !result.Finalizer {
+		t.Errorf("Expected finalizer result to be reported")
+	}
+}
+
+func TestInclude(t *testing.T) {
+	api := NewGomakefile()
+	build := api.AddRule("build", nil, func() error {
+		return nil
+	})
+
+	gomakefile := NewGomakefile()
+	gomakefile.AddRule("all", []*Rule{build}, func() error {
+		return nil
+	})
+
+	err := gomakefile.Include("api", api)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	rule, ok := gomakefile.Targets["api:build"]
+	if !ok || rule != build {
+		t.Fatalf("Expected gomakefile to have target api:build")
+	}
+
+	results := gomakefile.Make("all")
+	if results.Lookup("api:build") == nil {
+		t.Errorf("Expected api:build to be made as a dependency")
+	}
+
+	if Namespace(build.Target) != "api" {
+		t.Errorf("Expected namespace api but got %s", Namespace(build.Target))
+	}
+}
+
+func TestIncludeUnregistered(t *testing.T) {
+	gomakefile := NewGomakefile()
+	shared := gomakefile.AddRule("generate", nil, nil)
+
+	// Both Gomakefiles use a helper that isn't one of their targets
+	include := func(prefix string) *Rule {
+		other := NewGomakefile()
+		helper := NewRule("setup", nil, nil)
+		cleanup := NewRule("cleanup", nil, nil)
+		build := other.AddRule("build", []*Rule{helper, shared}, nil)
+		build.Finalizers = []*Rule{cleanup}
+
+		err := gomakefile.Include(prefix, other)
+		if err != nil {
+			t.Fatalf("Unexpected err: %s", err)
+		}
+
+		for _, rule := range []*Rule{helper, cleanup} {
+			if Namespace(rule.Target) != prefix {
+				t.Errorf("Expected namespace %s but got %s", prefix, rule.Target)
+			}
+		}
+
+		return helper
+	}
+
+	api := include("api")
+	web := include("web")
+	if api.Target == web.Target {
+		t.Errorf("Expected helpers to have different targets but got %s", api.Target)
+	}
+
+	// Rules of the including Gomakefile keep their names
+	expected := "generate"
+	if shared.Target != expected {
+		t.Errorf("Expected %s but got %s", expected, shared.Target)
+	}
+}
+
+func TestIncludeCollision(t *testing.T) {
+	api := NewGomakefile()
+	api.AddRule("build", nil, nil)
+
+	gomakefile := NewGomakefile()
+	gomakefile.AddRule("api:build", nil, nil)
+
+	err := gomakefile.Include("api", api)
+	if !errors.Is(err, ErrDuplicateTarget) {
+		t.Errorf("Expected %s but got %s", ErrDuplicateTarget, err)
+	}
+
+	if api.Targets["build"].Target != "build" {
+		t.Errorf("Expected targets to not be renamed on collision")
+	}
+}
+
+func TestIncludeTwice(t *testing.T) {
+	api := NewGomakefile()
+	build := api.AddRule("build", nil, nil)
+
+	gomakefile := NewGomakefile()
+	err := gomakefile.Include("api", api)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	if len(api.Targets) != 0 {
+		t.Errorf("Expected included targets to be moved but got %v", api.Targets)
+	}
+
+	// A rule shared with another Gomakefile isn't renamed again
+	web := NewGomakefile()
+	web.Targets["build"] = build
+
+	err = gomakefile.Include("web", web)
+	if !errors.Is(err, ErrIncluded) {
+		t.Errorf("Expected %s but got %s", ErrIncluded, err)
+	}
+
+	expected := "api:build"
+	if build.Target != expected {
+		t.Errorf("Expected %s but got %s", expected, build.Target)
+	}
+
+	_, ok := gomakefile.Targets["web:build"]
+	if ok {
+		t.Errorf("Expected web:build to not be included")
+	}
+}
+
+func TestAddAlias(t *testing.T) {
+	gomakefile := NewGomakefile()
+	build := gomakefile.AddRule("build", nil, nil)
+
+	err := gomakefile.AddAlias("b", build)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	names := gomakefile.Names(build)
+	if len(names) != 2 || names[0] != "build" || names[1] != "b" {
+		t.Errorf("Expected [build b] but got %s", names)
+	}
+
+	err = gomakefile.AddAlias("build", build)
+	if !errors.Is(err, ErrDuplicateTarget) {
+		t.Errorf("Expected %s but got %s", ErrDuplicateTarget, err)
+	}
+
+	err = gomakefile.AddAlias("t", NewRule("test", nil, nil))
+	if !errors.Is(err, ErrNoSuchTarget) {
+		t.Errorf("Expected %s but got %s", ErrNoSuchTarget, err)
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	gomakefile := NewGomakefile()
+	build := gomakefile.AddRule("build", nil, nil)
+
+	err := gomakefile.SetDefault(build)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	if gomakefile.Targets[""] != build {
+		t.Errorf("Expected build to be the default target")
+	}
+
+	// The default target isn't an alias
+	names := gomakefile.Names(build)
+	if len(names) != 1 {
+		t.Errorf("Expected [build] but got %s", names)
+	}
+
+	err = gomakefile.SetDefault(NewRule("test", nil, nil))
+	if !errors.Is(err, ErrNoSuchTarget) {
+		t.Errorf("Expected %s but got %s", ErrNoSuchTarget, err)
+	}
+}
+
+func TestAddGroup(t *testing.T) {
+	gomakefile := NewGomakefile()
+	build := gomakefile.AddRule("build", nil, func() error {
+		return nil
+	})
+	all := gomakefile.AddGroup("all", build)
+
+	if !all.IsGroup() || gomakefile.Targets["all"] != all {
+		t.Errorf("Expected gomakefile to have group all")
+	}
+
+	err := gomakefile.Make("all").Err()
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	gomakefile := NewGomakefile()
+	lint := gomakefile.AddRule("lint", nil, nil)
+	lint.Tags = []string{"check"}
+	integration := gomakefile.AddRule("integration", nil, nil)
+	integration.Tags = []string{"check", "slow"}
+	gomakefile.AddRule("build", nil, nil)
+
+	for _, test := range []struct {
+		tags, excludeTags []string
+		expected          []*Rule
+	}{
+		{[]string{"check"}, nil, []*Rule{integration, lint}},
+		{[]string{"check"}, []string{"slow"}, []*Rule{lint}},
+		{nil, []string{"check"}, []*Rule{gomakefile.Targets["build"]}},
+	} {
+		actual := gomakefile.Select(test.tags, test.excludeTags)
+		if len(actual) != len(test.expected) {
+			t.Errorf("Expected %d rules for %s but got %d", len(test.expected), test.tags, len(actual))
+			continue
+		}
+
+		for i, rule := range actual {
+			if rule != test.expected[i] {
+				t.Errorf("Expected %s but got %s", test.expected[i].Target, rule.Target)
+			}
+		}
+	}
+}
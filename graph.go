@@ -0,0 +1,169 @@
+package gomake
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// CycleError is returned when a rule depends on itself through its
+// dependencies.
+type CycleError struct {
+	// Targets are the targets in the cycle, starting and ending with the same
+	// target.
+	Targets []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(e.Targets, " -> "))
+}
+
+// collectGraph returns the set of rules that are evaluated when roots are.
+func collectGraph(roots ...*Rule) map[*Rule]bool {
+	graph := make(map[*Rule]bool)
+
+	var visit func(rule *Rule)
+	visit = func(rule *Rule) {
+		if graph[rule] {
+			return
+		}
+		graph[rule] = true
+
+		for _, dependency := range rule.pulled() {
+			visit(dependency)
+		}
+	}
+
+	for _, root := range roots {
+		visit(root)
+	}
+	return graph
+}
+
+// sortTopologically returns roots and every rule in their dependency graphs,
+// with each rule after the rules that must finish before it. It returns a
+// *CycleError if a rule depends on itself.
+func sortTopologically(roots ...*Rule) ([]*Rule, error) {
+	graph := collectGraph(roots...)
+
+	var (
+		sorted []*Rule
+		// visited is false for rules being visited and true once done
+		visited = make(map[*Rule]bool)
+		// path is the chain of dependencies to the rule being visited
+		path  []*Rule
+		visit func(rule *Rule) error
+	)
+
+	visit = func(rule *Rule) error {
+		done, ok := visited[rule]
+		if ok && done {
+			return nil
+		}
+
+		if ok {
+			return newCycleError(path, rule)
+		}
+
+		visited[rule] = false
+		path = append(path, rule)
+
+		for _, dependency := range rule.prerequisites(graph) {
+			err := visit(dependency)
+			if err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		visited[rule] = true
+		sorted = append(sorted, rule)
+		return nil
+	}
+
+	for _, root := range roots {
+		err := visit(root)
+		if err != nil {
+			return sorted, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// newCycleError returns a *CycleError for the cycle in path back to rule.
+func newCycleError(path []*Rule, rule *Rule) *CycleError {
+	var targets []string
+	for i := len(path) - 1; i >= 0; i-- {
+		targets = append([]string{path[i].Target}, targets...)
+		if path[i] == rule {
+			break
+		}
+	}
+
+	return &CycleError{
+		Targets: append(targets, rule.Target),
+	}
+}
+
+// WriteGraph writes the union of the dependency graphs of roots to w in the
+// Graphviz DOT language, with edges from each rule to the rules it depends on. Soft
+// dependencies are drawn dashed and order-only dependencies dotted, and rules
+// that are only order-only dependencies are drawn dotted since they aren't
+// evaluated with roots. Rules in the same namespace are drawn in a cluster
+// labelled with it.
+func WriteGraph(w io.Writer, roots ...*Rule) error {
+	sorted, err := sortTopologically(roots...)
+	if err != nil {
+		return err
+	}
+
+	graph := collectGraph(roots...)
+
+	var (
+		lines      []string
+		namespaces = make(map[string][]string)
+	)
+	for _, rule := range sorted {
+		lines = append(lines, fmt.Sprintf("\t%q;", rule.Target))
+
+		namespace := Namespace(rule.Target)
+		if namespace != "" {
+			namespaces[namespace] = append(namespaces[namespace], rule.Target)
+		}
+
+		for _, dependency := range rule.Dependencies {
+			lines = append(lines, fmt.Sprintf("\t%q -> %q;", rule.Target, dependency.Target))
+		}
+
+		for _, dependency := range rule.SoftDependencies {
+			lines = append(lines, fmt.Sprintf("\t%q -> %q [style=dashed, label=\"soft\"];", rule.Target, dependency.Target))
+		}
+
+		for _, dependency := range rule.OrderOnly {
+			if !graph[dependency] {
+				lines = append(lines, fmt.Sprintf("\t%q [style=dotted];", dependency.Target))
+			}
+			lines = append(lines, fmt.Sprintf("\t%q -> %q [style=dotted, label=\"order-only\"];", rule.Target, dependency.Target))
+		}
+	}
+
+	var names []string
+	for namespace := range namespaces {
+		names = append(names, namespace)
+	}
+	sort.Strings(names)
+
+	for _, namespace := range names {
+		lines = append(lines, fmt.Sprintf("\tsubgraph %q {", "cluster_"+namespace))
+		lines = append(lines, fmt.Sprintf("\t\tlabel=%q;", namespace))
+		for _, target := range namespaces[namespace] {
+			lines = append(lines, fmt.Sprintf("\t\t%q;", target))
+		}
+		lines = append(lines, "\t}")
+	}
+
+	_, err = fmt.Fprintf(w, "digraph gomake {\n%s\n}\n", strings.Join(lines, "\n"))
+	return err
+}
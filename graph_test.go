@@ -0,0 +1,99 @@
+package gomake
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEvaluateCycle(t *testing.T) {
+	rule1 := NewRule("rule1", nil, func() error {
+		return nil
+	})
+	rule2 := NewRule("rule2", []*Rule{rule1}, func() error {
+		return nil
+	})
+	rule3 := NewRule("rule3", []*Rule{rule2}, func() error {
+		return nil
+	})
+	rule1.Dependencies = []*Rule{rule3}
+	rule4 := NewRule("rule4", []*Rule{rule3}, func() error {
+		return nil
+	})
+
+	var cycleErr *CycleError
+	err := Evaluate(rule4).Err()
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("Expected CycleError but got %v", err)
+	}
+
+	expected := "dependency cycle: rule3 -> rule2 -> rule1 -> rule3"
+	if cycleErr.Error() != expected {
+		t.Errorf("Expected %q but got %q", expected, cycleErr)
+	}
+}
+
+func TestSortTopologically(t *testing.T) {
+	rule1 := NewRule("rule1", nil, nil)
+	rule2 := NewRule("rule2", []*Rule{rule1}, nil)
+	rule3 := NewRule("rule3", []*Rule{rule1, rule2}, nil)
+
+	sorted, err := sortTopologically(rule3)
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	expected := []*Rule{rule1, rule2, rule3}
+	if len(sorted) != len(expected) {
+		t.Fatalf("Expected %d rules but got %d", len(expected), len(sorted))
+	}
+
+	for i, rule := range sorted {
+		if rule != expected[i] {
+			t.Errorf("Expected %s at %d but got %s", expected[i].Target, i, rule.Target)
+		}
+	}
+}
+
+func TestWriteGraph(t *testing.T) {
+	build := NewRule("build", nil, nil)
+	lint := NewRule("lint", nil, nil)
+	clean := NewRule("clean", nil, nil)
+	test := NewRule("test", []*Rule{build}, nil)
+	test.SoftDependencies = []*Rule{lint}
+	test.OrderOnly = []*Rule{clean}
+
+	var buf bytes.Buffer
+	err := WriteGraph(&buf, test)
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	for _, line := range []string{
+		`"test" -> "build";`,
+		`"test" -> "lint" [style=dashed, label="soft"];`,
+		`"clean" [style=dotted];`,
+		`"test" -> "clean" [style=dotted, label="order-only"];`,
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Expected %s in graph but got %s", line, buf.String())
+		}
+	}
+}
+
+func TestWriteGraphNamespaces(t *testing.T) {
+	build := NewRule("api:build", nil, nil)
+	test := NewRule("test", []*Rule{build}, nil)
+
+	var buf bytes.Buffer
+	err := WriteGraph(&buf, test)
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	expected := "\tsubgraph \"cluster_api\" {\n\t\tlabel=\"api\";\n\t\t\"api:build\";\n\t}"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("Expected %s in graph but got %s", expected, buf.String())
+	}
+}
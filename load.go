@@ -0,0 +1,560 @@
+package gomake
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadError is an error in a declarative Gomakefile.
+type LoadError struct {
+	// File is the name of the file with the error.
+	File string
+	// Line is the line of the error, starting at 1.
+	Line int
+	// Err is the error.
+	Err error
+}
+
+func (e *LoadError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+}
+
+// Unwrap returns the error.
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// LoadFile loads a new Gomakefile from the declarative file at path.
+func LoadFile(path string) (*Gomakefile, error) {
+	gomakefile := NewGomakefile()
+	err := gomakefile.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return gomakefile, nil
+}
+
+// LoadFile loads the targets in the declarative file at path into the
+// Gomakefile.
+func (g *Gomakefile) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return g.Load(f, path)
+}
+
+// Load loads the targets in a declarative Gomakefile read from r into the
+// Gomakefile, with errors reported in file. Loaded targets can depend on the
+// targets already in the Gomakefile and the other way around.
+//
+// The file is written in a subset of TOML, with a table for each target whose
+// commands are run with sh one after the other:
+//
+//	default = "build"
+//
+//	[build]
+//	description = "Builds the binary"
+//	dependencies = ["generate"]
+//	commands = ["go build -o bin/app ./cmd/app"]
+//	env = ["CGO_ENABLED=0"]
+//	inputs = ["*.go", "cmd/..."]
+//	outputs = ["bin/app"]
+//
+// Targets also take a dir to run commands in, a timeout, tags and aliases.
+// Values are strings or arrays of strings, and a target without commands is a
+// group. Nothing is loaded if there are any errors, each of which is a
+// *LoadError.
+func (g *Gomakefile) Load(r io.Reader, file string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	doc, err := parseDocument(string(data))
+	if err != nil {
+		var lineErr *lineError
+		if errors.As(err, &lineErr) {
+			return &LoadError{File: file, Line: lineErr.line, Err: lineErr.err}
+		}
+		return err
+	}
+
+	loader := &loader{
+		gomakefile: g,
+		file:       file,
+		rules:      make(map[string]*Rule),
+	}
+	return loader.load(doc)
+}
+
+// loader builds the rules of a parsed document.
+type loader struct {
+	gomakefile *Gomakefile
+	file       string
+	// rules are the loaded rules by target, which aren't in the Gomakefile
+	// until they're all loaded without errors.
+	rules map[string]*Rule
+	errs  []error
+}
+
+func (l *loader) errorf(line int, format string, args ...any) {
+	l.errs = append(l.errs, &LoadError{
+		File: l.file,
+		Line: line,
+		Err:  fmt.Errorf(format, args...),
+	})
+}
+
+func (l *loader) load(doc *document) error {
+	for _, key := range doc.root.keys() {
+		if key != "default" {
+			l.errorf(doc.root.values[key].line, "unknown key %q", key)
+		}
+	}
+
+	for _, table := range doc.tables {
+		_, ok := l.gomakefile.Targets[table.name]
+		if ok {
+			l.errorf(table.line, "%s: %w", table.name, ErrDuplicateTarget)
+			continue
+		}
+
+		l.rules[table.name] = l.newRule(table)
+	}
+
+	// Resolve references once every target is known
+	aliased := make(map[string]bool)
+	for _, table := range doc.tables {
+		rule, ok := l.rules[table.name]
+		if !ok {
+			continue
+		}
+
+		dependencies := table.values["dependencies"]
+		for _, target := range dependencies.list {
+			dependency := l.lookup(target)
+			if dependency == nil {
+				l.errorf(dependencies.line, "%s: unknown dependency %q", table.name, target)
+				continue
+			}
+
+			rule.Dependencies = append(rule.Dependencies, dependency)
+		}
+
+		aliases := table.values["aliases"]
+		for _, alias := range aliases.list {
+			if alias == "" || aliased[alias] || l.lookup(alias) != nil {
+				l.errorf(aliases.line, "%s: alias %q: %w", table.name, alias, ErrDuplicateTarget)
+			}
+			aliased[alias] = true
+		}
+	}
+
+	defaultTarget, ok := doc.root.values["default"]
+	switch {
+	case !ok:
+	case defaultTarget.kind != kindString:
+		l.errorf(defaultTarget.line, "default must be a %s", kindString)
+	case l.lookup(defaultTarget.str) == nil:
+		l.errorf(defaultTarget.line, "unknown default target %q", defaultTarget.str)
+	}
+
+	if len(l.errs) > 0 {
+		return errors.Join(l.errs...)
+	}
+
+	for _, table := range doc.tables {
+		rule := l.rules[table.name]
+		l.gomakefile.Targets[table.name] = rule
+
+		for _, alias := range table.values["aliases"].list {
+			l.gomakefile.Targets[alias] = rule
+		}
+	}
+
+	if ok {
+		return l.gomakefile.SetDefault(l.lookup(defaultTarget.str))
+	}
+
+	return nil
+}
+
+// lookup returns the rule for target, whether it's loaded or already in the
+// Gomakefile.
+func (l *loader) lookup(target string) *Rule {
+	rule, ok := l.rules[target]
+	if ok {
+		return rule
+	}
+
+	return l.gomakefile.Targets[target]
+}
+
+// newRule returns the rule for a target's table, without its dependencies.
+func (l *loader) newRule(table *table) *Rule {
+	rule := NewRule(table.name, nil, nil)
+
+	var (
+		commands, env []string
+		dir           string
+	)
+	for _, key := range table.keys() {
+		value := table.values[key]
+
+		kind, ok := targetKeys[key]
+		if !ok {
+			l.errorf(value.line, "%s: unknown key %q", table.name, key)
+			continue
+		}
+
+		if kind != value.kind {
+			l.errorf(value.line, "%s: %s must be a %s", table.name, key, kind)
+			continue
+		}
+
+		switch key {
+		case "description":
+			rule.Description = value.str
+		case "dir":
+			dir = value.str
+		case "timeout":
+			timeout, err := time.ParseDuration(value.str)
+			if err != nil {
+				l.errorf(value.line, "%s: invalid timeout %q", table.name, value.str)
+			}
+			rule.Timeout = timeout
+		case "commands":
+			commands = value.list
+		case "env":
+			env = value.list
+		case "inputs":
+			rule.Inputs = value.list
+		case "outputs":
+			rule.Outputs = value.list
+		case "tags":
+			rule.Tags = value.list
+		}
+	}
+
+	if len(commands) > 0 {
+		rule.Action = shellAction(commands, env, dir)
+	}
+
+	return rule
+}
+
+// shellAction returns an Action that runs each of commands with sh in dir
+// with env set.
+func shellAction(commands, env []string, dir string) Action {
+	return func(ctx *Context) error {
+		for _, script := range commands {
+			cmd := Shell(ctx, script)
+			cmd.Dir = dir
+			cmd.Env = env
+
+			err := cmd.Run()
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// valueKind is the type of a value in a declarative Gomakefile.
+type valueKind string
+
+const (
+	kindString valueKind = "string"
+	kindList   valueKind = "list of strings"
+)
+
+// targetKeys are the keys of a target's table and the kind of their values.
+var targetKeys = map[string]valueKind{
+	"description":  kindString,
+	"dir":          kindString,
+	"timeout":      kindString,
+	"dependencies": kindList,
+	"commands":     kindList,
+	"env":          kindList,
+	"inputs":       kindList,
+	"outputs":      kindList,
+	"tags":         kindList,
+	"aliases":      kindList,
+}
+
+// document is a parsed declarative Gomakefile.
+type document struct {
+	// root are the keys before the first table.
+	root *table
+	// tables are the tables in the order they appear.
+	tables []*table
+}
+
+// table is a table of keys and their values.
+type table struct {
+	name   string
+	line   int
+	values map[string]value
+}
+
+// keys returns the keys of the table in order.
+func (t *table) keys() []string {
+	var keys []string
+	for key := range t.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// value is a string or list of strings and the line it's on.
+type value struct {
+	kind valueKind
+	str  string
+	list []string
+	line int
+}
+
+// lineError is a syntax error on a line.
+type lineError struct {
+	line int
+	err  error
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.err)
+}
+
+// parseDocument parses the TOML subset of declarative Gomakefiles.
+func parseDocument(data string) (*document, error) {
+	tokens, err := tokenize(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	return p.parse()
+}
+
+// tokenKind is the kind of a token.
+type tokenKind int
+
+const (
+	tokenNewline tokenKind = iota
+	tokenBare
+	tokenString
+	tokenPunct
+	tokenEOF
+)
+
+// token is a lexical token of a declarative Gomakefile.
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+// tokenize splits data into tokens, dropping comments and whitespace.
+func tokenize(data string) ([]token, error) {
+	var (
+		tokens []token
+		line   = 1
+	)
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '\n':
+			tokens = append(tokens, token{kind: tokenNewline, line: line})
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+		case c == '[' || c == ']' || c == '=' || c == ',':
+			tokens = append(tokens, token{kind: tokenPunct, text: string(c), line: line})
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(data) && data[end] != c && data[end] != '\n' {
+				if c == '"' && data[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(data) || data[end] != c {
+				return nil, &lineError{line: line, err: errors.New("unterminated string")}
+			}
+
+			text := data[i+1 : end]
+			if c == '"' {
+				var err error
+				text, err = strconv.Unquote(data[i : end+1])
+				if err != nil {
+					return nil, &lineError{line: line, err: fmt.Errorf("invalid string %s", data[i:end+1])}
+				}
+			}
+
+			tokens = append(tokens, token{kind: tokenString, text: text, line: line})
+			i = end + 1
+		default:
+			end := i
+			for end < len(data) && strings.IndexByte(" \t\r\n#[]=,\"'", data[end]) < 0 {
+				end++
+			}
+
+			tokens = append(tokens, token{kind: tokenBare, text: data[i:end], line: line})
+			i = end
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, line: line}), nil
+}
+
+// parser parses tokens into a document.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// skipNewlines skips any newlines before the next token.
+func (p *parser) skipNewlines() {
+	for p.peek().kind == tokenNewline {
+		p.next()
+	}
+}
+
+func (p *parser) errorf(tok token, format string, args ...any) error {
+	return &lineError{line: tok.line, err: fmt.Errorf(format, args...)}
+}
+
+func (p *parser) parse() (*document, error) {
+	doc := &document{
+		root: &table{values: make(map[string]value)},
+	}
+	current := doc.root
+	names := make(map[string]bool)
+
+	for {
+		p.skipNewlines()
+
+		tok := p.next()
+		switch {
+		case tok.kind == tokenEOF:
+			return doc, nil
+		case tok.kind == tokenPunct && tok.text == "[":
+			name := p.next()
+			if name.kind != tokenBare && name.kind != tokenString {
+				return nil, p.errorf(name, "expected table name")
+			}
+
+			// The default target is set with the top-level default key
+			if name.text == "" {
+				return nil, p.errorf(name, "empty target name")
+			}
+
+			if names[name.text] {
+				return nil, p.errorf(name, "duplicate target %q", name.text)
+			}
+			names[name.text] = true
+
+			end := p.next()
+			if end.kind != tokenPunct || end.text != "]" {
+				return nil, p.errorf(end, "expected ] after table name")
+			}
+
+			current = &table{
+				name:   name.text,
+				line:   tok.line,
+				values: make(map[string]value),
+			}
+			doc.tables = append(doc.tables, current)
+		case tok.kind == tokenBare || tok.kind == tokenString:
+			_, ok := current.values[tok.text]
+			if ok {
+				return nil, p.errorf(tok, "duplicate key %q", tok.text)
+			}
+
+			equals := p.next()
+			if equals.kind != tokenPunct || equals.text != "=" {
+				return nil, p.errorf(equals, "expected = after key %q", tok.text)
+			}
+
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			current.values[tok.text] = value
+		default:
+			return nil, p.errorf(tok, "unexpected %q", tok.text)
+		}
+
+		// Each key and table header is on its own line
+		end := p.peek()
+		if end.kind != tokenNewline && end.kind != tokenEOF {
+			return nil, p.errorf(end, "expected newline but got %q", end.text)
+		}
+	}
+}
+
+// parseValue parses a string or an array of strings, which may span lines.
+func (p *parser) parseValue() (value, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokenString:
+		return value{kind: kindString, str: tok.text, line: tok.line}, nil
+	case tok.kind == tokenPunct && tok.text == "[":
+		v := value{kind: kindList, list: []string{}, line: tok.line}
+		for {
+			p.skipNewlines()
+
+			elem := p.next()
+			if elem.kind == tokenPunct && elem.text == "]" {
+				return v, nil
+			}
+
+			if elem.kind != tokenString {
+				return value{}, p.errorf(elem, "expected string in array")
+			}
+			v.list = append(v.list, elem.text)
+
+			p.skipNewlines()
+			sep := p.next()
+			if sep.kind == tokenPunct && sep.text == "]" {
+				return v, nil
+			}
+
+			if sep.kind != tokenPunct || sep.text != "," {
+				return value{}, p.errorf(sep, "expected , or ] in array")
+			}
+		}
+	}
+
+	return value{}, p.errorf(tok, "expected string or array of strings")
+}
@@ -0,0 +1,201 @@
+package gomake
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testGomakefile = `# Builds the app
+default = "build"
+
+[generate]
+commands = ["echo generating"]
+
+[build]
+description = "Builds the app"
+dependencies = [
+	"generate",
+	"lint", # defined in Go
+]
+commands = ["echo building"]
+env = ["CGO_ENABLED=0"]
+tags = ['ci']
+aliases = ["b"]
+
+["all"]
+dependencies = ["build"]
+`
+
+func TestLoad(t *testing.T) {
+	gomakefile := NewGomakefile()
+	lint := gomakefile.AddRule("lint", nil, func() error {
+		return nil
+	})
+
+	err := gomakefile.Load(strings.NewReader(testGomakefile), "Gomakefile.toml")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	build := gomakefile.Targets["build"]
+	if build == nil || gomakefile.Targets[""] != build || gomakefile.Targets["b"] != build {
+		t.Fatalf("Expected build to be the default target with alias b")
+	}
+
+	if build.Description != "Builds the app" || !build.HasTag("ci") {
+		t.Errorf("Expected build to have description and tags")
+	}
+
+	if len(build.Dependencies) != 2 || build.Dependencies[1] != lint {
+		t.Errorf("Expected build to depend on generate and lint")
+	}
+
+	if !gomakefile.Targets["all"].IsGroup() {
+		t.Errorf("Expected all to be a group")
+	}
+
+	err = gomakefile.MakeWith(context.Background(), &Evaluator{Stdout: io.Discard}, "all").Err()
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, test := range []struct {
+		data     string
+		expected string
+	}{
+		{"[build]\ndependencies = [\"missing\"]\n", `Gomakefile.toml:2: build: unknown dependency "missing"`},
+		{"[build]\n\ncommands = \"go build\"\n", `Gomakefile.toml:3: build: commands must be a list of strings`},
+		{"[build]\nunknown = \"\"\n", `Gomakefile.toml:2: build: unknown key "unknown"`},
+		{"[build]\ncommands = [\"go build\"\n", `Gomakefile.toml:3: expected , or ] in array`},
+		{"[build]\n[build]\n", `Gomakefile.toml:2: duplicate target "build"`},
+		{"[build]\n\n[\"\"]\n", `Gomakefile.toml:3: empty target name`},
+		{"default = \"missing\"\n", `Gomakefile.toml:1: unknown default target "missing"`},
+	} {
+		err := NewGomakefile().Load(strings.NewReader(test.data), "Gomakefile.toml")
+
+		var loadErr *LoadError
+		if !errors.As(err, &loadErr) {
+			t.Errorf("Expected LoadError but got %v", err)
+			continue
+		}
+
+		if err.Error() != test.expected {
+			t.Errorf("Expected %q but got %q", test.expected, err)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Gomakefile.toml")
+	err := os.WriteFile(path, []byte("[build]\ncommands = [\"true\"]\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	gomakefile, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	_, ok := gomakefile.Targets["build"]
+	if !ok {
+		t.Errorf("Expected gomakefile to have target build")
+	}
+}
+
+func TestEvaluateOutputsUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "main.go")
+	output := filepath.Join(dir, "app")
+	for _, path := range []string{input, output} {
+		err := os.WriteFile(path, nil, 0644)
+		if err != nil {
+			t.Fatalf("Unexpected err: %s", err)
+		}
+	}
+
+	// Make the output older than the input
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(output, old, old)
+
+	evaluated := 0
+	build := NewRule("build", nil, func() error {
+		evaluated++
+		return os.Chtimes(output, time.Now(), time.Now())
+	})
+	build.Inputs = []string{input}
+	build.Outputs = []string{output}
+
+	for _, expected := range []Status{StatusSucceeded, StatusUpToDate} {
+		actual := Evaluate(build).Lookup("build").Status
+		if actual != expected {
+			t.Errorf("Expected %s but got %s", expected, actual)
+		}
+	}
+
+	if evaluated != 1 {
+		t.Errorf("Expected build to be evaluated once but was %d times", evaluated)
+	}
+}
+
+func TestEvaluateOutputsDependencyNewer(t *testing.T) {
+	dir := t.TempDir()
+	generated := filepath.Join(dir, "generated.go")
+	output := filepath.Join(dir, "app")
+	for _, path := range []string{generated, output} {
+		err := os.WriteFile(path, nil, 0644)
+		if err != nil {
+			t.Fatalf("Unexpected err: %s", err)
+		}
+	}
+
+	// An earlier run generated code but failed to build the app with it
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(output, old, old)
+
+	generate := NewRule("generate", nil, nil)
+	generate.Outputs = []string{generated}
+	generate.Action = func(ctx *Context) error {
+		return nil
+	}
+
+	build := NewRule("build", []*Rule{NewGroup("all", generate)}, func() error {
+		return os.Chtimes(output, time.Now(), time.Now())
+	})
+	build.Outputs = []string{output}
+
+	for _, expected := range []Status{StatusSucceeded, StatusUpToDate} {
+		actual := Evaluate(build).Lookup("build").Status
+		if actual != expected {
+			t.Errorf("Expected %s but got %s", expected, actual)
+		}
+	}
+}
+
+func TestEvaluateOutputsMissingInputs(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "app")
+	err := os.WriteFile(output, nil, 0644)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	build := NewRule("build", nil, func() error {
+		return nil
+	})
+	build.Inputs = []string{filepath.Join(dir, "*.go")}
+	build.Outputs = []string{output}
+
+	actual := Evaluate(build).Lookup("build").Status
+	if actual != StatusSucceeded {
+		t.Errorf("Expected %s but got %s", StatusSucceeded, actual)
+	}
+}
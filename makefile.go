@@ -0,0 +1,721 @@
+package gomake
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	gotoken "go/token"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUnsupported is wrapped by the warnings for Makefile constructs that
+	// can't be translated.
+	ErrUnsupported = errors.New("unsupported")
+
+	// assignmentRegexp matches a variable assignment, capturing the variable,
+	// the operator and the value.
+	assignmentRegexp = regexp.MustCompile(`^([A-Za-z0-9_.-]+)\s*(::=|:=|\?=|\+=|=)\s*(.*)$`)
+
+	// unsupportedDirectives are the directives that aren't translated.
+	unsupportedDirectives = map[string]bool{
+		"ifeq": true, "ifneq": true, "ifdef": true, "ifndef": true, "else": true, "endif": true,
+		"include": true, "-include": true, "sinclude": true,
+		"export": true, "unexport": true, "override": true, "vpath": true,
+	}
+
+	// specialTargets are the targets with special meaning to make.
+	specialTargets = map[string]bool{
+		".PHONY": true, ".SUFFIXES": true, ".DEFAULT": true, ".PRECIOUS": true,
+		".INTERMEDIATE": true, ".SECONDARY": true, ".SECONDEXPANSION": true,
+		".DELETE_ON_ERROR": true, ".IGNORE": true, ".LOW_RESOLUTION_TIME": true,
+		".SILENT": true, ".EXPORT_ALL_VARIABLES": true, ".NOTPARALLEL": true,
+		".ONESHELL": true, ".POSIX": true, ".NOTINTERMEDIATE": true,
+	}
+)
+
+// Makefile is a GNU Makefile parsed by ParseMakefile. Only explicit rules,
+// their prerequisites and recipes, simple variables and .PHONY are
+// understood, and everything else is reported in Warnings.
+type Makefile struct {
+	// Rules are the explicit rules, one for each target in the order they're
+	// first defined.
+	Rules []*MakefileRule
+	// Variables are the unexpanded values of the variables.
+	Variables map[string]string
+	// Phony are the targets declared as prerequisites of .PHONY.
+	Phony map[string]bool
+	// Default is the default goal, which is the first target unless set with
+	// .DEFAULT_GOAL.
+	Default string
+	// Warnings are the constructs that couldn't be translated, each of which
+	// is a *LoadError wrapping ErrUnsupported.
+	Warnings []error
+
+	file string
+	// warned are the warnings already reported, so that repeated expansions
+	// of the same line only report them once.
+	warned map[string]bool
+}
+
+// MakefileRule is an explicit rule for a target in a Makefile.
+type MakefileRule struct {
+	// Target is the file or phony target the rule makes.
+	Target string
+	// Prerequisites are the targets or files that must be made first.
+	Prerequisites []string
+	// OrderOnly are the prerequisites after a "|", which must only be made
+	// first if they don't exist.
+	OrderOnly []string
+	// Recipe are the unexpanded lines of shell commands that make the target.
+	Recipe []string
+	// Line is the line the rule is first defined on.
+	Line int
+}
+
+// ParseMakefileFile parses the Makefile at path.
+func ParseMakefileFile(path string) (*Makefile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseMakefile(f, path)
+}
+
+// ParseMakefile parses a Makefile read from r, with warnings reported in file.
+func ParseMakefile(r io.Reader, file string) (*Makefile, error) {
+	m := &Makefile{
+		Variables: make(map[string]string),
+		Phony:     make(map[string]bool),
+		file:      file,
+		warned:    make(map[string]bool),
+	}
+
+	lines, err := readMakefileLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make(map[string]*MakefileRule)
+
+	// current are the rules the recipe lines that follow belong to
+	var current []*MakefileRule
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if strings.HasPrefix(line.text, "\t") {
+			if current == nil {
+				m.warnf(line.number, "recipe without a rule")
+				continue
+			}
+
+			recipe := strings.TrimPrefix(line.text, "\t")
+			for _, rule := range current {
+				rule.Recipe = append(rule.Recipe, recipe)
+			}
+			continue
+		}
+
+		text := strings.TrimSpace(stripComment(line.text))
+		if text == "" {
+			continue
+		}
+		current = nil
+
+		directive, _, _ := strings.Cut(text, " ")
+		if directive == "define" {
+			m.warnf(line.number, "multi-line variable")
+			for i < len(lines) && strings.TrimSpace(lines[i].text) != "endef" {
+				i++
+			}
+			continue
+		}
+
+		if unsupportedDirectives[directive] {
+			m.warnf(line.number, "directive %s", directive)
+			continue
+		}
+
+		match := assignmentRegexp.FindStringSubmatch(text)
+		if match != nil {
+			m.assign(match[1], match[2], match[3], line.number)
+			continue
+		}
+
+		current = m.parseRule(text, line.number, rules)
+	}
+
+	if m.Default == "" && len(m.Rules) > 0 {
+		m.Default = m.Rules[0].Target
+	}
+
+	return m, nil
+}
+
+// makefileLine is a logical line of a Makefile, with continuations joined.
+type makefileLine struct {
+	text   string
+	number int
+}
+
+// readMakefileLines returns the logical lines read from r. Continuations of
+// recipe lines are kept for the shell, and other continuations are joined with
+// a space.
+func readMakefileLines(r io.Reader) ([]makefileLine, error) {
+	var (
+		lines  []makefileLine
+		number int
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		number++
+		text := scanner.Text()
+		start := number
+
+		for strings.HasSuffix(text, "\\") && scanner.Scan() {
+			number++
+			if strings.HasPrefix(text, "\t") {
+				text += "\n" + strings.TrimPrefix(scanner.Text(), "\t")
+			} else {
+				text = strings.TrimSuffix(text, "\\") + " " + strings.TrimSpace(scanner.Text())
+			}
+		}
+
+		lines = append(lines, makefileLine{text: text, number: start})
+	}
+
+	return lines, scanner.Err()
+}
+
+// stripComment returns line without a trailing comment.
+func stripComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] != '\\') {
+			return line[:i]
+		}
+	}
+
+	return line
+}
+
+// assign assigns value to the variable name with the assignment operator op.
+func (m *Makefile) assign(name, op, value string, line int) {
+	switch op {
+	case ":=", "::=":
+		m.Variables[name] = m.expand(value, nil, false, line)
+	case "?=":
+		_, ok := m.Variables[name]
+		if !ok {
+			m.Variables[name] = value
+		}
+	case "+=":
+		if m.Variables[name] == "" {
+			m.Variables[name] = value
+		} else {
+			m.Variables[name] += " " + value
+		}
+	default:
+		m.Variables[name] = value
+	}
+
+	if name == ".DEFAULT_GOAL" {
+		m.Default = m.expand(m.Variables[name], nil, false, line)
+	}
+}
+
+// parseRule parses the rule in text and returns the rules for its targets,
+// which are empty if none of them can be translated so that the recipe is
+// skipped too.
+func (m *Makefile) parseRule(text string, line int, rules map[string]*MakefileRule) []*MakefileRule {
+	left, right, ok := strings.Cut(text, ":")
+	if !ok {
+		m.warnf(line, "line %q", text)
+		return nil
+	}
+
+	current := []*MakefileRule{}
+	if strings.HasPrefix(right, ":") {
+		m.warnf(line, "double-colon rule")
+		return current
+	}
+
+	right, recipe, hasRecipe := strings.Cut(right, ";")
+	if strings.Contains(right, "=") {
+		m.warnf(line, "target-specific variable")
+		return current
+	}
+
+	normal, orderOnly, _ := strings.Cut(right, "|")
+	targets := strings.Fields(m.expand(left, nil, false, line))
+	prerequisites := strings.Fields(m.expand(normal, nil, false, line))
+
+	for _, target := range targets {
+		switch {
+		case target == ".PHONY":
+			for _, prerequisite := range prerequisites {
+				m.Phony[prerequisite] = true
+			}
+			continue
+		case specialTargets[target]:
+			m.warnf(line, "special target %s", target)
+			continue
+		case strings.Contains(target, "%"):
+			m.warnf(line, "pattern rule %s", target)
+			continue
+		}
+
+		rule, ok := rules[target]
+		if !ok {
+			rule = &MakefileRule{
+				Target: target,
+				Line:   line,
+			}
+			rules[target] = rule
+			m.Rules = append(m.Rules, rule)
+		}
+
+		// Like make, a later recipe for the same target overrides the earlier
+		if len(rule.Recipe) > 0 {
+			m.warnf(line, "overriding recipe for %s", target)
+			rule.Recipe = nil
+		}
+
+		rule.Prerequisites = append(rule.Prerequisites, prerequisites...)
+		rule.OrderOnly = append(rule.OrderOnly, strings.Fields(m.expand(orderOnly, nil, false, line))...)
+		if hasRecipe {
+			rule.Recipe = append(rule.Recipe, strings.TrimSpace(recipe))
+		}
+
+		current = append(current, rule)
+	}
+
+	return current
+}
+
+// warnf reports a construct on line that can't be translated.
+func (m *Makefile) warnf(line int, format string, args ...any) {
+	err := &LoadError{
+		File: m.file,
+		Line: line,
+		Err:  fmt.Errorf("%w: %s", ErrUnsupported, fmt.Sprintf(format, args...)),
+	}
+
+	if m.warned[err.Error()] {
+		return
+	}
+	m.warned[err.Error()] = true
+
+	m.Warnings = append(m.Warnings, err)
+}
+
+// expand expands the variable references in s, with the automatic variables
+// in auto. In recipes, references to undefined variables are left for the
+// shell to expand from the environment.
+func (m *Makefile) expand(s string, auto map[string]string, recipe bool, line int) string {
+	return m.expandVisiting(s, auto, recipe, line, make(map[string]bool))
+}
+
+func (m *Makefile) expandVisiting(s string, auto map[string]string, recipe bool, line int, visiting map[string]bool) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			buf.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		var name string
+		switch s[i] {
+		case '$':
+			buf.WriteByte('$')
+			continue
+		case '(', '{':
+			closing := byte(')')
+			if s[i] == '{' {
+				closing = '}'
+			}
+
+			end := strings.IndexByte(s[i:], closing)
+			if end < 0 {
+				m.warnf(line, "unterminated variable reference")
+				buf.WriteString(s[i-1:])
+				return buf.String()
+			}
+
+			name = s[i+1 : i+end]
+			i += end
+		default:
+			name = string(s[i])
+		}
+
+		// Function calls have arguments after the name
+		if strings.ContainsAny(name, " \t,") {
+			m.warnf(line, "function $(%s)", name)
+			buf.WriteString("$(" + name + ")")
+			continue
+		}
+
+		value, ok := auto[name]
+		if ok {
+			buf.WriteString(value)
+			continue
+		}
+
+		value, ok = m.Variables[name]
+		switch {
+		case ok && visiting[name]:
+			m.warnf(line, "recursive variable %s", name)
+		case ok:
+			visiting[name] = true
+			buf.WriteString(m.expandVisiting(value, auto, recipe, line, visiting))
+			delete(visiting, name)
+		case isAutomaticVariable(name):
+			if len(name) > 1 {
+				name = "(" + name + ")"
+			}
+			m.warnf(line, "automatic variable $%s", name)
+		case recipe && len(name) > 1:
+			buf.WriteString("${" + name + "}")
+		}
+	}
+
+	return buf.String()
+}
+
+// isAutomaticVariable returns whether name is one of make's automatic
+// variables, such as "@" or its directory part "@D".
+func isAutomaticVariable(name string) bool {
+	switch len(name) {
+	case 1:
+		return strings.Contains("@<^?*+|%", name)
+	case 2:
+		return isAutomaticVariable(name[:1]) && (name[1] == 'D' || name[1] == 'F')
+	}
+
+	return false
+}
+
+// recipe returns the recipe lines of rule expanded, with whether make would
+// ignore the errors of each.
+func (m *Makefile) recipe(rule *MakefileRule) ([]string, []bool) {
+	auto := map[string]string{
+		"@": rule.Target,
+		"^": strings.Join(dedupe(rule.Prerequisites), " "),
+	}
+	if len(rule.Prerequisites) > 0 {
+		auto["<"] = rule.Prerequisites[0]
+	}
+
+	var (
+		scripts []string
+		ignore  []bool
+	)
+	for _, line := range rule.Recipe {
+		script := m.expand(line, auto, true, rule.Line)
+
+		// Strip prefixes that make interprets rather than the shell
+		ignoreErr := false
+		for len(script) > 0 && strings.ContainsRune("@-+", rune(script[0])) {
+			if script[0] == '-' {
+				ignoreErr = true
+			}
+			script = strings.TrimSpace(script[1:])
+		}
+
+		if script == "" {
+			continue
+		}
+
+		scripts = append(scripts, script)
+		ignore = append(ignore, ignoreErr)
+	}
+
+	return scripts, ignore
+}
+
+// Gomakefile converts the Makefile into a Gomakefile. Prerequisites that are
+// targets become dependencies, prerequisites that aren't phony become inputs,
+// and targets that aren't phony are outputs, so rules are up to date like
+// they would be with make. Targets without a recipe become groups.
+func (m *Makefile) Gomakefile() (*Gomakefile, error) {
+	gomakefile := NewGomakefile()
+	for _, mrule := range m.Rules {
+		gomakefile.Targets[mrule.Target] = NewRule(mrule.Target, nil, nil)
+	}
+
+	for _, mrule := range m.Rules {
+		rule := gomakefile.Targets[mrule.Target]
+
+		for _, prerequisite := range dedupe(mrule.Prerequisites) {
+			dependency, ok := gomakefile.Targets[prerequisite]
+			if ok {
+				rule.Dependencies = append(rule.Dependencies, dependency)
+			}
+
+			// Files made by other rules are inputs too, so the rule is made
+			// again when they're newer
+			if !ok || !m.Phony[prerequisite] {
+				rule.Inputs = append(rule.Inputs, prerequisite)
+			}
+		}
+
+		for _, prerequisite := range dedupe(mrule.OrderOnly) {
+			dependency, ok := gomakefile.Targets[prerequisite]
+			if ok {
+				rule.OrderOnly = append(rule.OrderOnly, dependency)
+			}
+		}
+
+		if !m.Phony[mrule.Target] && len(mrule.Recipe) > 0 {
+			rule.Outputs = []string{mrule.Target}
+		}
+
+		scripts, ignore := m.recipe(mrule)
+		if len(scripts) > 0 {
+			rule.Action = recipeAction(scripts, ignore)
+		}
+	}
+
+	if m.Default != "" {
+		err := gomakefile.SetDefault(gomakefile.Targets[m.Default])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return gomakefile, nil
+}
+
+// recipeAction returns an Action that runs each of scripts with sh, stopping
+// at the first that fails unless its error is ignored.
+func recipeAction(scripts []string, ignore []bool) Action {
+	return func(ctx *Context) error {
+		for i, script := range scripts {
+			err := Shell(ctx, script).Run()
+			if err != nil && !ignore[i] {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// WriteGo writes Go source for package pkg to w, with a NewGomakefile function
+// that adds the rules of the Makefile with AddAction and AddGroup. A main
+// function running it is included if pkg is "main".
+func (m *Makefile) WriteGo(w io.Writer, pkg string) error {
+	gomakefile, err := m.Gomakefile()
+	if err != nil {
+		return err
+	}
+
+	var roots []*Rule
+	for _, mrule := range m.Rules {
+		roots = append(roots, gomakefile.Targets[mrule.Target])
+	}
+
+	// Rules must be declared before the rules that depend on them
+	sorted, err := sortTopologically(roots...)
+	if err != nil {
+		return err
+	}
+
+	mrules := make(map[*Rule]*MakefileRule)
+	for _, mrule := range m.Rules {
+		mrules[gomakefile.Targets[mrule.Target]] = mrule
+	}
+
+	// Only declare variables for rules that are referenced
+	referenced := make(map[*Rule]bool)
+	for _, rule := range sorted {
+		for _, dependency := range rule.Dependencies {
+			referenced[dependency] = true
+		}
+		for _, dependency := range rule.OrderOnly {
+			referenced[dependency] = true
+		}
+
+		if len(rule.Inputs) > 0 || len(rule.OrderOnly) > 0 || len(rule.Outputs) > 0 {
+			referenced[rule] = true
+		}
+	}
+	referenced[gomakefile.Targets[""]] = true
+
+	names := goIdentifiers(sorted)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Generated by gomake import-makefile from %s.\n\n", m.file)
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	if pkg == "main" {
+		fmt.Fprintf(&buf, "import (\n\"os\"\n\n\"github.com/hinshun/gomake\"\n)\n\n")
+		fmt.Fprintf(&buf, "func main() {\ngomake.Gomake(NewGomakefile()).RunAndExit(os.Args)\n}\n\n")
+	} else {
+		fmt.Fprintf(&buf, "import \"github.com/hinshun/gomake\"\n\n")
+	}
+
+	fmt.Fprintf(&buf, "// NewGomakefile returns the Gomakefile translated from %s.\n", m.file)
+	fmt.Fprintf(&buf, "func NewGomakefile() *gomake.Gomakefile {\n")
+	fmt.Fprintf(&buf, "gomakefile := gomake.NewGomakefile()\n\n")
+
+	for _, rule := range sorted {
+		name := names[rule]
+		if referenced[rule] {
+			fmt.Fprintf(&buf, "%s := ", name)
+		}
+
+		var dependencies []string
+		for _, dependency := range rule.Dependencies {
+			dependencies = append(dependencies, names[dependency])
+		}
+
+		scripts, ignore := m.recipe(mrules[rule])
+		if len(scripts) == 0 {
+			fmt.Fprintf(&buf, "gomakefile.AddGroup(%q", rule.Target)
+			for _, dependency := range dependencies {
+				fmt.Fprintf(&buf, ", %s", dependency)
+			}
+			fmt.Fprintf(&buf, ")\n")
+		} else {
+			fmt.Fprintf(&buf, "gomakefile.AddAction(%q, %s, func(ctx *gomake.Context) error {\n", rule.Target, goRules(dependencies))
+
+			assign := ":="
+			for i, script := range scripts {
+				last := i == len(scripts)-1
+				switch {
+				case ignore[i]:
+					fmt.Fprintf(&buf, "gomake.Shell(ctx, %q).Run()\n", script)
+					if last {
+						fmt.Fprintf(&buf, "return nil\n")
+					}
+				case last:
+					fmt.Fprintf(&buf, "return gomake.Shell(ctx, %q).Run()\n", script)
+				default:
+					fmt.Fprintf(&buf, "err %s gomake.Shell(ctx, %q).Run()\nif err != nil {\nreturn err\n}\n\n", assign, script)
+					assign = "="
+				}
+			}
+			fmt.Fprintf(&buf, "})\n")
+		}
+
+		if len(rule.Inputs) > 0 {
+			fmt.Fprintf(&buf, "%s.Inputs = %s\n", name, goStrings(rule.Inputs))
+		}
+		if len(rule.Outputs) > 0 {
+			fmt.Fprintf(&buf, "%s.Outputs = %s\n", name, goStrings(rule.Outputs))
+		}
+		if len(rule.OrderOnly) > 0 {
+			var orderOnly []string
+			for _, dependency := range rule.OrderOnly {
+				orderOnly = append(orderOnly, names[dependency])
+			}
+			fmt.Fprintf(&buf, "%s.OrderOnly = %s\n", name, goRules(orderOnly))
+		}
+		fmt.Fprintf(&buf, "\n")
+	}
+
+	if m.Default != "" {
+		fmt.Fprintf(&buf, "gomakefile.SetDefault(%s)\n\n", names[gomakefile.Targets[m.Default]])
+	}
+	fmt.Fprintf(&buf, "return gomakefile\n}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(src)
+	return err
+}
+
+// goIdentifiers returns a unique Go identifier for each of rules derived from
+// its target, such as buildLinux for "build-linux".
+func goIdentifiers(rules []*Rule) map[*Rule]string {
+	names := make(map[*Rule]string)
+	used := map[string]bool{"gomakefile": true, "gomake": true, "ctx": true, "err": true, "os": true}
+
+	for _, rule := range rules {
+		var words []string
+		for _, word := range strings.FieldsFunc(rule.Target, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			words = append(words, withFirstRune(word, len(words) > 0))
+		}
+
+		name := strings.Join(words, "")
+		first, _ := utf8.DecodeRuneInString(name)
+		if name == "" || !unicode.IsLetter(first) || gotoken.IsKeyword(name) {
+			name = "rule" + withFirstRune(name, true)
+		}
+
+		unique := name
+		for i := 2; used[unique]; i++ {
+			unique = fmt.Sprintf("%s%d", name, i)
+		}
+		used[unique] = true
+		names[rule] = unique
+	}
+
+	return names
+}
+
+// withFirstRune returns word with its first rune in upper case if upper is
+// true, or lower case otherwise.
+func withFirstRune(word string, upper bool) string {
+	r, size := utf8.DecodeRuneInString(word)
+	if size == 0 {
+		return word
+	}
+
+	if upper {
+		r = unicode.ToUpper(r)
+	} else {
+		r = unicode.ToLower(r)
+	}
+
+	return string(r) + word[size:]
+}
+
+// goStrings returns the Go source for a slice of strings.
+func goStrings(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+
+	return fmt.Sprintf("[]string{%s}", strings.Join(quoted, ", "))
+}
+
+// goRules returns the Go source for a slice of the rules named names.
+func goRules(names []string) string {
+	if len(names) == 0 {
+		return "nil"
+	}
+
+	return fmt.Sprintf("[]*gomake.Rule{%s}", strings.Join(names, ", "))
+}
+
+// dedupe returns values without duplicates, in order.
+func dedupe(values []string) []string {
+	seen := make(map[string]bool)
+
+	var deduped []string
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			deduped = append(deduped, value)
+		}
+	}
+
+	return deduped
+}
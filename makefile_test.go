@@ -0,0 +1,230 @@
+package gomake
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testMakefile = `# Builds the app
+GO ?= go
+BIN := bin/app
+FLAGS = -v \
+	-race
+
+.PHONY: all test clean
+
+all: $(BIN) test
+
+$(BIN): main.go | bin
+	$(GO) build -o $@ $<
+
+bin:
+	mkdir -p $@
+
+test:
+	@$(GO) test $(FLAGS) ./...
+
+clean:
+	-rm -rf bin
+	echo $$HOME $(HOME)
+
+ifeq ($(CI),true)
+endif
+
+%.o: %.c
+	cc -c $<
+`
+
+func TestParseMakefile(t *testing.T) {
+	makefile, err := ParseMakefile(strings.NewReader(testMakefile), "Makefile")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	var targets []string
+	for _, rule := range makefile.Rules {
+		targets = append(targets, rule.Target)
+	}
+
+	expected := "all bin/app bin test clean"
+	if strings.Join(targets, " ") != expected {
+		t.Errorf("Expected %s but got %s", expected, targets)
+	}
+
+	if makefile.Default != "all" || !makefile.Phony["test"] {
+		t.Errorf("Expected default all and phony test")
+	}
+
+	var lines []string
+	for _, warning := range makefile.Warnings {
+		if !errors.Is(warning, ErrUnsupported) {
+			t.Errorf("Expected %s but got %s", ErrUnsupported, warning)
+		}
+		lines = append(lines, warning.Error())
+	}
+
+	expected = "Makefile:24: unsupported: directive ifeq, Makefile:25: unsupported: directive endif, Makefile:27: unsupported: pattern rule %.o"
+	if strings.Join(lines, ", ") != expected {
+		t.Errorf("Expected %s but got %s", expected, lines)
+	}
+
+	scripts, _ := makefile.recipe(makefile.Rules[1])
+	if len(scripts) != 1 || scripts[0] != "go build -o bin/app main.go" {
+		t.Errorf("Expected automatic variables to be expanded but got %s", scripts)
+	}
+
+	scripts, ignore := makefile.recipe(makefile.Rules[4])
+	if len(scripts) != 2 || scripts[0] != "rm -rf bin" || !ignore[0] || scripts[1] != "echo $HOME ${HOME}" {
+		t.Errorf("Expected prefixes to be stripped and shell variables kept but got %s", scripts)
+	}
+}
+
+func TestParseMakefileAutomaticDirectory(t *testing.T) {
+	makefile, err := ParseMakefile(strings.NewReader("out/app:\n\tmkdir -p $(@D) ${@F}\n"), "Makefile")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	makefile.recipe(makefile.Rules[0])
+
+	var lines []string
+	for _, warning := range makefile.Warnings {
+		lines = append(lines, warning.Error())
+	}
+
+	expected := "Makefile:1: unsupported: automatic variable $(@D), Makefile:1: unsupported: automatic variable $(@F)"
+	if strings.Join(lines, ", ") != expected {
+		t.Errorf("Expected %s but got %s", expected, lines)
+	}
+}
+
+func TestGoIdentifiers(t *testing.T) {
+	var rules []*Rule
+	for _, target := range []string{"build-linux", "Éclair", "über-build", "3d", "func", "build_linux"} {
+		rules = append(rules, NewRule(target, nil, nil))
+	}
+
+	names := goIdentifiers(rules)
+
+	var actual []string
+	for _, rule := range rules {
+		actual = append(actual, names[rule])
+	}
+
+	expected := "buildLinux éclair überBuild rule3d ruleFunc buildLinux2"
+	if strings.Join(actual, " ") != expected {
+		t.Errorf("Expected %s but got %s", expected, actual)
+	}
+}
+
+func TestMakefileGomakefile(t *testing.T) {
+	makefile, err := ParseMakefile(strings.NewReader(testMakefile), "Makefile")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	gomakefile, err := makefile.Gomakefile()
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	app := gomakefile.Targets["bin/app"]
+	if len(app.Inputs) != 1 || app.Inputs[0] != "main.go" || len(app.Outputs) != 1 {
+		t.Errorf("Expected bin/app to have main.go as input and itself as output")
+	}
+
+	if len(app.OrderOnly) != 1 || app.OrderOnly[0] != gomakefile.Targets["bin"] {
+		t.Errorf("Expected bin/app to have bin as order-only dependency")
+	}
+
+	if !gomakefile.Targets["all"].IsGroup() || gomakefile.Targets[""] != gomakefile.Targets["all"] {
+		t.Errorf("Expected all to be the default group")
+	}
+
+	if len(gomakefile.Targets["test"].Outputs) != 0 {
+		t.Errorf("Expected phony test to have no outputs")
+	}
+}
+
+func TestMakefileGomakefileTargetInputs(t *testing.T) {
+	makefile, err := ParseMakefile(strings.NewReader("app: gen main.go\n\tgo build\n\ngen:\n\tgo generate\n"), "Makefile")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	gomakefile, err := makefile.Gomakefile()
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	// The file made by gen is an input of app as well as a dependency
+	app := gomakefile.Targets["app"]
+	expected := "gen main.go"
+	if strings.Join(app.Inputs, " ") != expected {
+		t.Errorf("Expected inputs %s but got %s", expected, app.Inputs)
+	}
+
+	if len(app.Dependencies) != 1 || app.Dependencies[0] != gomakefile.Targets["gen"] {
+		t.Errorf("Expected app to depend on gen")
+	}
+}
+
+func TestMakefileWriteGo(t *testing.T) {
+	makefile, err := ParseMakefile(strings.NewReader(testMakefile), "Makefile")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	var buf bytes.Buffer
+	err = makefile.WriteGo(&buf, "main")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	for _, line := range []string{
+		`bin := gomakefile.AddAction("bin", nil, func(ctx *gomake.Context) error {`,
+		`binApp := gomakefile.AddAction("bin/app", nil, func(ctx *gomake.Context) error {`,
+		`	return gomake.Shell(ctx, "go build -o bin/app main.go").Run()`,
+		`binApp.OrderOnly = []*gomake.Rule{bin}`,
+		`all := gomakefile.AddGroup("all", binApp, test)`,
+		`gomake.Shell(ctx, "rm -rf bin").Run()`,
+		`gomakefile.SetDefault(all)`,
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Expected %s in source but got %s", line, buf.String())
+		}
+	}
+}
+
+func TestGomakeImportMakefile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Makefile")
+	err := os.WriteFile(path, []byte("build:\n\tgo build\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	// Capture the generated source
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = w
+	err = Gomake(NewGomakefile()).Run([]string{"gomake", "import-makefile", "--makefile=" + path, "--package=build"})
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	src, _ := io.ReadAll(r)
+	if !strings.HasPrefix(string(src), "// Generated by gomake import-makefile") || !strings.Contains(string(src), "package build") {
+		t.Errorf("Expected generated source but got %s", src)
+	}
+}
@@ -0,0 +1,103 @@
+package gomake
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Axis is a parameter of a Matrix and the values it takes.
+type Axis struct {
+	// Name is the name of the parameter.
+	Name string
+	// Values are the values the parameter takes, in order.
+	Values []string
+}
+
+// Params are the values of the parameters of a rule expanded from a Matrix,
+// keyed by parameter name.
+type Params map[string]string
+
+// Matrix is the cartesian product of the values of its axes, which a rule
+// definition is expanded over.
+type Matrix struct {
+	// Axes are the parameters of the matrix, in the order their values appear
+	// in target names.
+	Axes []Axis
+	// Exclude are combinations of parameters not to expand. A combination is
+	// excluded if it has every parameter value of any of them.
+	Exclude []Params
+}
+
+// Expand returns every combination of parameter values in the matrix that
+// isn't excluded, varying the values of the last axis fastest.
+func (m *Matrix) Expand() []Params {
+	combinations := []Params{{}}
+	for _, axis := range m.Axes {
+		var next []Params
+		for _, combination := range combinations {
+			for _, value := range axis.Values {
+				params := make(Params, len(combination)+1)
+				for name, v := range combination {
+					params[name] = v
+				}
+				params[axis.Name] = value
+
+				next = append(next, params)
+			}
+		}
+		combinations = next
+	}
+
+	var expanded []Params
+	for _, params := range combinations {
+		if !m.excluded(params) {
+			expanded = append(expanded, params)
+		}
+	}
+
+	return expanded
+}
+
+func (m *Matrix) excluded(params Params) bool {
+	for _, exclude := range m.Exclude {
+		matched := true
+		for name, value := range exclude {
+			if params[name] != value {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Target returns the name of the target expanded from target with params, such
+// as "build[linux/amd64]".
+func (m *Matrix) Target(target string, params Params) string {
+	values := make([]string, len(m.Axes))
+	for i, axis := range m.Axes {
+		values[i] = params[axis.Name]
+	}
+
+	return fmt.Sprintf("%s[%s]", target, strings.Join(values, "/"))
+}
+
+// AddMatrix expands a rule evaluated by action over matrix and adds a rule for
+// every combination of parameters to the Gomakefile, each depending on
+// dependencies. The parameters of each rule are in its Params. It returns a
+// group rule for target that depends on all of them.
+func (g *Gomakefile) AddMatrix(target string, matrix *Matrix, dependencies []*Rule, action Action) *Rule {
+	var members []*Rule
+	for _, params := range matrix.Expand() {
+		rule := g.AddAction(matrix.Target(target, params), dependencies, action)
+		rule.Params = params
+		members = append(members, rule)
+	}
+
+	return g.AddGroup(target, members...)
+}
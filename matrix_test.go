@@ -0,0 +1,75 @@
+package gomake
+
+import (
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestMatrixExpand(t *testing.T) {
+	matrix := &Matrix{
+		Axes: []Axis{
+			{Name: "os", Values: []string{"linux", "darwin"}},
+			{Name: "arch", Values: []string{"amd64", "arm64"}},
+		},
+		Exclude: []Params{
+			{"os": "darwin", "arch": "amd64"},
+		},
+	}
+
+	var actual []string
+	for _, params := range matrix.Expand() {
+		actual = append(actual, matrix.Target("build", params))
+	}
+
+	expected := []string{"build[linux/amd64]", "build[linux/arm64]", "build[darwin/arm64]"}
+	if len(actual) != len(expected) {
+		t.Fatalf("Expected %s but got %s", expected, actual)
+	}
+
+	for i, target := range actual {
+		if target != expected[i] {
+			t.Errorf("Expected %s but got %s", expected[i], target)
+		}
+	}
+}
+
+func TestAddMatrix(t *testing.T) {
+	gomakefile := NewGomakefile()
+	matrix := &Matrix{
+		Axes: []Axis{
+			{Name: "go", Values: []string{"1.21", "1.22"}},
+		},
+	}
+
+	var (
+		actual []string
+		// Protects actual
+		mu sync.Mutex
+	)
+	test := gomakefile.AddMatrix("test", matrix, nil, func(ctx *Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		actual = append(actual, ctx.Param("go"))
+		return nil
+	})
+
+	if !test.IsGroup() || len(test.Dependencies) != 2 {
+		t.Fatalf("Expected group depending on every expanded rule")
+	}
+
+	rule, ok := gomakefile.Targets["test[1.21]"]
+	if !ok || rule.Params["go"] != "1.21" {
+		t.Errorf("Expected gomakefile to have target test[1.21]")
+	}
+
+	err := gomakefile.Make("test").Err()
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	sort.Strings(actual)
+	if len(actual) != 2 || actual[0] != "1.21" || actual[1] != "1.22" {
+		t.Errorf("Expected [1.21 1.22] but got %s", actual)
+	}
+}
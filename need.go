@@ -0,0 +1,127 @@
+package gomake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrDependencyFailed is returned by Context.Need when a needed rule
+	// doesn't succeed.
+	ErrDependencyFailed = errors.New("dependency did not succeed")
+)
+
+// Need evaluates rules as dependencies of the rule being evaluated, and waits
+// for them to finish. It's for dependencies that are only known once the rule
+// is evaluating. Rules already being evaluated aren't evaluated again, and the
+// resources held by the rule are released while it waits.
+//
+// Need returns a *CycleError if any of rules depends on the rule being
+// evaluated, or an error wrapping ErrDependencyFailed if any of them doesn't
+// succeed.
+func (c *Context) Need(rules ...*Rule) error {
+	if c.evaluation == nil {
+		return fmt.Errorf("%s: need: rule is not being evaluated", c.Rule.Target)
+	}
+
+	states, err := c.evaluation.need(c.Rule, rules)
+	if err != nil {
+		return err
+	}
+
+	// Let other rules use the resources while waiting, so that the needed
+	// rules can run
+	if c.held != nil {
+		c.held.suspend()
+	}
+
+	for _, state := range states {
+		select {
+		case <-state.done:
+		case <-c.Done():
+			return context.Cause(c)
+		}
+	}
+
+	if c.held != nil {
+		err = c.held.acquire(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for i, state := range states {
+		if !state.result.succeeded() {
+			errs = append(errs, fmt.Errorf("%s %s: %w", rules[i].Target, state.result.Status, ErrDependencyFailed))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// need schedules rules, and the rules in their dependency graphs, as
+// dependencies of rule. It returns the state of each of rules.
+func (ev *evaluation) need(rule *Rule, rules []*Rule) ([]*ruleState, error) {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+
+	var states []*ruleState
+	for _, needed := range rules {
+		sorted, err := sortTopologically(needed)
+		if err != nil {
+			return nil, err
+		}
+
+		// Rules would wait on each other forever
+		path := ev.path(needed, rule)
+		if path != nil {
+			return nil, newCycleError(append([]*Rule{rule}, path[:len(path)-1]...), rule)
+		}
+
+		ev.needs[rule] = append(ev.needs[rule], needed)
+		ev.schedule(sorted)
+		states = append(states, ev.states[needed])
+	}
+
+	return states, nil
+}
+
+// path returns a chain of rules from rule to target, each of which waits for
+// the next, or nil if rule doesn't wait for target. ev.mu must be held.
+func (ev *evaluation) path(rule, target *Rule) []*Rule {
+	visited := make(map[*Rule]bool)
+
+	var visit func(rule *Rule) []*Rule
+	visit = func(rule *Rule) []*Rule {
+		if rule == target {
+			return []*Rule{rule}
+		}
+
+		if visited[rule] {
+			return nil
+		}
+		visited[rule] = true
+
+		// Order-only dependencies are only waited on if they're evaluated
+		prerequisites := append(rule.pulled(), ev.needs[rule]...)
+		for _, dependency := range rule.OrderOnly {
+			_, ok := ev.states[dependency]
+			if ok {
+				prerequisites = append(prerequisites, dependency)
+			}
+		}
+
+		for _, dependency := range prerequisites {
+			path := visit(dependency)
+			if path != nil {
+				return append([]*Rule{rule}, path...)
+			}
+		}
+
+		return nil
+	}
+
+	return visit(rule)
+}
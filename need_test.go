@@ -0,0 +1,85 @@
+package gomake
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNeed(t *testing.T) {
+	generated := false
+	generate := NewRule("generate", nil, func() error {
+		generated = true
+		return nil
+	})
+
+	build := &Rule{
+		Target: "build",
+		Action: func(ctx *Context) error {
+			err := ctx.Need(generate)
+			if err != nil {
+				return err
+			}
+
+			if !generated {
+				return errors.New("needed rule not evaluated")
+			}
+			return nil
+		},
+	}
+
+	results := (&Evaluator{Jobs: 1}).Evaluate(build)
+	err := results.Err()
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	// Needed rules are reported along with the rest of the graph
+	if len(results) != 2 || results.Lookup("generate").Status != StatusSucceeded {
+		t.Errorf("Expected result for needed rule")
+	}
+}
+
+func TestNeedFailed(t *testing.T) {
+	intentional := errors.New("intentional")
+	generate := NewRule("generate", nil, func() error {
+		return intentional
+	})
+
+	build := &Rule{
+		Target: "build",
+		Action: func(ctx *Context) error {
+			return ctx.Need(generate)
+		},
+	}
+
+	result := Evaluate(build).Lookup("build")
+	if !errors.Is(result.Err, ErrDependencyFailed) {
+		t.Errorf("Expected %s but got %s", ErrDependencyFailed, result.Err)
+	}
+}
+
+func TestNeedCycle(t *testing.T) {
+	build := &Rule{
+		Target: "build",
+	}
+
+	test := NewRule("test", []*Rule{build}, func() error {
+		return nil
+	})
+
+	build.Action = func(ctx *Context) error {
+		return ctx.Need(test)
+	}
+
+	result := Evaluate(test).Lookup("build")
+
+	var cycleErr *CycleError
+	if !errors.As(result.Err, &cycleErr) {
+		t.Fatalf("Expected cycle error but got %s", result.Err)
+	}
+
+	expected := "dependency cycle: build -> test -> build"
+	if cycleErr.Error() != expected {
+		t.Errorf("Expected %s but got %s", expected, cycleErr)
+	}
+}
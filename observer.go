@@ -0,0 +1,43 @@
+package gomake
+
+import "time"
+
+// EventKind is the kind of change in a rule's evaluation.
+type EventKind int
+
+const (
+	// EventStarted is sent right before a rule is evaluated.
+	EventStarted EventKind = iota
+	// EventFinished is sent right after a rule is evaluated.
+	EventFinished
+)
+
+// Event describes a change in a rule's evaluation.
+type Event struct {
+	// Kind is the kind of change.
+	Kind EventKind
+	// Rule is the rule the event is for.
+	Rule *Rule
+	// Time is when the event happened.
+	Time time.Time
+	// Attempt is the number of the attempt at evaluating the rule, starting
+	// from 1. Rules with a RetryPolicy start and finish once per attempt.
+	Attempt int
+	// Err is the error the rule evaluated with, only set for EventFinished.
+	Err error
+}
+
+// Observer is notified of events as rules are evaluated. Rules are evaluated
+// concurrently, so Observers must be safe to call from multiple goroutines.
+type Observer interface {
+	Observe(event Event)
+}
+
+// ObserverFunc is an adapter to allow the use of ordinary functions as
+// Observers.
+type ObserverFunc func(event Event)
+
+// Observe calls f(event).
+func (f ObserverFunc) Observe(event Event) {
+	f(event)
+}
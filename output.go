@@ -0,0 +1,175 @@
+package gomake
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// OutputMode is how the output of rules evaluating in parallel is written.
+type OutputMode string
+
+const (
+	// OutputPrefix streams each line of a rule's output as soon as it's
+	// written, prefixed with the rule's target.
+	OutputPrefix OutputMode = "prefix"
+	// OutputBuffered holds a rule's output until it finishes evaluating, then
+	// writes it all at once.
+	OutputBuffered OutputMode = "buffered"
+	// OutputFailed is like OutputBuffered, but only writes the output of rules
+	// that evaluate with an error.
+	OutputFailed OutputMode = "failed"
+)
+
+// ParseOutputMode returns the OutputMode with name.
+func ParseOutputMode(name string) (OutputMode, error) {
+	mode := OutputMode(name)
+	switch mode {
+	case OutputPrefix, OutputBuffered, OutputFailed:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("unknown output mode %q", name)
+}
+
+// ruleOutput routes the output of a rule to the writers shared by all rules.
+type ruleOutput struct {
+	// mu protects the shared writers so that output from different rules
+	// doesn't interleave.
+	mu     *sync.Mutex
+	mode   OutputMode
+	stdout io.Writer
+	stderr io.Writer
+
+	prefixStdout *prefixWriter
+	prefixStderr *prefixWriter
+
+	// bufMu protects the buffers when OutputBuffered or OutputFailed.
+	bufMu     sync.Mutex
+	bufStdout bytes.Buffer
+	bufStderr bytes.Buffer
+}
+
+func newRuleOutput(mu *sync.Mutex, mode OutputMode, target string, stdout, stderr io.Writer) *ruleOutput {
+	prefix := fmt.Sprintf("[%s] ", target)
+	return &ruleOutput{
+		mu:           mu,
+		mode:         mode,
+		stdout:       stdout,
+		stderr:       stderr,
+		prefixStdout: &prefixWriter{mu: mu, w: stdout, prefix: prefix},
+		prefixStderr: &prefixWriter{mu: mu, w: stderr, prefix: prefix},
+	}
+}
+
+// Stdout returns the writer for the rule's output.
+func (o *ruleOutput) Stdout() io.Writer {
+	if o.mode == OutputPrefix {
+		return o.prefixStdout
+	}
+
+	return &lockedWriter{mu: &o.bufMu, w: &o.bufStdout}
+}
+
+// Stderr returns the writer for the rule's errors.
+func (o *ruleOutput) Stderr() io.Writer {
+	if o.mode == OutputPrefix {
+		return o.prefixStderr
+	}
+
+	return &lockedWriter{mu: &o.bufMu, w: &o.bufStderr}
+}
+
+// Close writes out anything remaining once the rule has evaluated with err.
+func (o *ruleOutput) Close(err error) {
+	switch o.mode {
+	case OutputPrefix:
+		o.prefixStdout.Flush()
+		o.prefixStderr.Flush()
+	case OutputFailed:
+		if err == nil {
+			return
+		}
+		fallthrough
+	case OutputBuffered:
+		o.bufMu.Lock()
+		defer o.bufMu.Unlock()
+
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		o.stdout.Write(o.bufStdout.Bytes())
+		o.stderr.Write(o.bufStderr.Bytes())
+	}
+}
+
+// prefixWriter writes each complete line written to it prefixed to w.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+
+	// buf holds the incomplete last line written
+	buf []byte
+}
+
+// Write writes out every complete line in p, holding onto the rest until the
+// line is completed or the writer is flushed.
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		err := p.writeLine(p.buf[:i+1])
+		if err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+
+	return len(b), nil
+}
+
+// Flush writes out the incomplete last line, if any.
+func (p *prefixWriter) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.buf) == 0 {
+		return nil
+	}
+
+	err := p.writeLine(append(p.buf, '\n'))
+	p.buf = nil
+	return err
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	_, err := io.WriteString(p.w, p.prefix)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.w.Write(line)
+	return err
+}
+
+// lockedWriter serializes writes to w.
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.w.Write(b)
+}
@@ -0,0 +1,126 @@
+package gomake
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		mu  sync.Mutex
+	)
+	writer := &prefixWriter{mu: &mu, w: &buf, prefix: "[test] "}
+
+	fmt.Fprintf(writer, "ok pkg1\nok ")
+	fmt.Fprintf(writer, "pkg2\npartial")
+
+	expected := "[test] ok pkg1\n[test] ok pkg2\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, buf.String())
+	}
+
+	writer.Flush()
+	expected += "[test] partial\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, buf.String())
+	}
+}
+
+func newOutputTestRules() (*Rule, *Rule) {
+	pass := NewRule("pass", nil, nil)
+	pass.Action = func(ctx *Context) error {
+		fmt.Fprintf(ctx.Stdout, "pass line 1\npass line 2\n")
+		return nil
+	}
+
+	intentional := errors.New("intentional")
+	fail := NewRule("fail", []*Rule{pass}, nil)
+	fail.Action = func(ctx *Context) error {
+		fmt.Fprintf(ctx.Stdout, "fail line 1\n")
+		fmt.Fprintf(ctx.Stderr, "fail line 2\n")
+		return intentional
+	}
+
+	return pass, fail
+}
+
+func TestOutputPrefix(t *testing.T) {
+	_, fail := newOutputTestRules()
+
+	var buf bytes.Buffer
+	evaluator := &Evaluator{
+		Output: OutputPrefix,
+		Stdout: &buf,
+		Stderr: &buf,
+	}
+	evaluator.Evaluate(fail)
+
+	expected := "[pass] pass line 1\n[pass] pass line 2\n[fail] fail line 1\n[fail] fail line 2\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, buf.String())
+	}
+}
+
+func TestOutputBuffered(t *testing.T) {
+	_, fail := newOutputTestRules()
+
+	var stdout, stderr bytes.Buffer
+	evaluator := &Evaluator{
+		Output: OutputBuffered,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	evaluator.Evaluate(fail)
+
+	expected := "pass line 1\npass line 2\nfail line 1\n"
+	if stdout.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, stdout.String())
+	}
+
+	expected = "fail line 2\n"
+	if stderr.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, stderr.String())
+	}
+}
+
+func TestOutputFailed(t *testing.T) {
+	_, fail := newOutputTestRules()
+
+	var buf bytes.Buffer
+	evaluator := &Evaluator{
+		Output: OutputFailed,
+		Stdout: &buf,
+		Stderr: &buf,
+	}
+	evaluator.Evaluate(fail)
+
+	if strings.Contains(buf.String(), "pass") {
+		t.Errorf("Expected no output from passing rule but got %q", buf.String())
+	}
+
+	expected := "fail line 1\nfail line 2\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q but got %q", expected, buf.String())
+	}
+}
+
+func TestParseOutputMode(t *testing.T) {
+	mode, err := ParseOutputMode("buffered")
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	if mode != OutputBuffered {
+		t.Errorf("Expected %s but got %s", OutputBuffered, mode)
+	}
+
+	_, err = ParseOutputMode("unknown")
+	if err == nil {
+		t.Errorf("Expected err for unknown output mode")
+	}
+}
@@ -3,10 +3,10 @@ package cli
 import (
 	"errors"
 	"fmt"
-	"html/template"
 	"os"
 	"strings"
 	"text/tabwriter"
+	"text/template"
 )
 
 var (
@@ -80,11 +80,15 @@ USAGE:
 VERSION:
    {{.Version}}
 
-COMMANDS:{{range .Commands}}
-   {{.Name}}{{if .Description}}{{"\t"}}{{.Description}}{{end}}{{end}}
+COMMANDS:{{range .Commands.InCategory ""}}
+   {{.Name}}{{if .Aliases}}, {{join .Aliases ", "}}{{end}}{{if .Description}}{{"\t"}}{{.Description}}{{end}}{{range .Flags}}
+     --{{.Name}}{{if .TakesValue}}=value{{end}}{{"\t"}}{{.Description}}{{if .Default}} (default: {{.Default}}){{end}}{{end}}{{end}}{{range $category := .Commands.Categories}}
+
+   {{$category}}:{{range $.Commands.InCategory $category}}
+     {{.Name}}{{if .Aliases}}, {{join .Aliases ", "}}{{end}}{{if .Description}}{{"\t"}}{{.Description}}{{end}}{{end}}{{end}}
 
 OPTIONS:{{range .Flags}}
-   --{{.Name}}{{if .Aliases}}, {{join .Aliases ", "}}{{end}}{{"\t"}}{{.Description}}{{end}}
+   --{{.Name}}{{if .TakesValue}}=value{{end}}{{if .Aliases}}, {{join .Aliases ", "}}{{end}}{{"\t"}}{{.Description}}{{if .Default}} (default: {{.Default}}){{end}}{{end}}
 `
 	funcMap := template.FuncMap{
 		"join": strings.Join,
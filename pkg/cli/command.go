@@ -1,13 +1,21 @@
 package cli
 
+import "sort"
+
 // Command is a subcommand for an App.
 type Command struct {
 	// Name is the name of the subcommand.
 	Name string
+	// Aliases are other names the subcommand can be invoked as.
+	Aliases []string
 	// Description is a brief text about the subcommand.
 	Description string
+	// Category is the heading the subcommand is listed under in help, if any.
+	Category string
 	// Action is the function to call when the command is invoked.
 	Action Action
+	// Flags are the flags only the subcommand takes, given after its name.
+	Flags Flags
 }
 
 // Commands is a sortable list of commands.
@@ -28,10 +36,58 @@ func (c Commands) Swap(i, j int) {
 	c[i], c[j] = c[j], c[i]
 }
 
+// Categories returns the sorted categories of the commands, excluding
+// commands without one.
+func (c Commands) Categories() []string {
+	seen := make(map[string]bool)
+
+	var categories []string
+	for _, command := range c {
+		if command.Category == "" || seen[command.Category] {
+			continue
+		}
+		seen[command.Category] = true
+		categories = append(categories, command.Category)
+	}
+
+	sort.Strings(categories)
+	return categories
+}
+
+// InCategory returns the commands in category, in order.
+func (c Commands) InCategory(category string) Commands {
+	var commands Commands
+	for _, command := range c {
+		if command.Category == category {
+			commands = append(commands, command)
+		}
+	}
+
+	return commands
+}
+
+// ActionForName returns the action of the command that matches name or one of
+// its aliases.
 func (c Commands) ActionForName(name string) Action {
+	command := c.CommandForName(name)
+	if command == nil {
+		return nil
+	}
+
+	return command.Action
+}
+
+// CommandForName returns the command that matches name or one of its aliases.
+func (c Commands) CommandForName(name string) *Command {
 	for _, command := range c {
 		if name == command.Name {
-			return command.Action
+			return command
+		}
+
+		for _, alias := range command.Aliases {
+			if name == alias {
+				return command
+			}
 		}
 	}
 
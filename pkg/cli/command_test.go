@@ -24,3 +24,51 @@ func TestSortCommands(t *testing.T) {
 		}
 	}
 }
+
+func TestCommandCategories(t *testing.T) {
+	commands := Commands{
+		{Name: "build"},
+		{Name: "web:build", Category: "web"},
+		{Name: "api:build", Category: "api"},
+		{Name: "api:test", Category: "api"},
+	}
+
+	categories := commands.Categories()
+	if len(categories) != 2 || categories[0] != "api" || categories[1] != "web" {
+		t.Errorf("Expected [api web] but got %s", categories)
+	}
+
+	api := commands.InCategory("api")
+	if len(api) != 2 || api[0].Name != "api:build" || api[1].Name != "api:test" {
+		t.Errorf("Expected api commands in order")
+	}
+
+	uncategorized := commands.InCategory("")
+	if len(uncategorized) != 1 || uncategorized[0].Name != "build" {
+		t.Errorf("Expected only build to have no category")
+	}
+}
+
+func TestActionForAlias(t *testing.T) {
+	called := false
+	commands := Commands{
+		{
+			Name:    "build",
+			Aliases: []string{"b"},
+			Action: func(ctx *Context) error {
+				called = true
+				return nil
+			},
+		},
+	}
+
+	action := commands.ActionForName("b")
+	if action == nil {
+		t.Fatalf("Expected action for alias")
+	}
+
+	action(nil)
+	if !called {
+		t.Errorf("Expected alias to invoke the command's action")
+	}
+}
@@ -7,16 +7,33 @@ type Context struct {
 	// Action is the context wrapped function to be evaluated.
 	Action func() error
 
-	flagSet map[string]struct{}
+	flags   Flags
+	flagSet map[string]string
 }
 
 // NewContext initializes a new context for the Action to run in.
 func NewContext(app *App, args []string) (*Context, error) {
 	// Parse the flags first
-	flagSet := ParseFlags(app.Flags, args)
+	flagSet, n := parseFlags(app.Flags, args)
+	flags := app.Flags
+	args = args[n:]
+
+	// Parse the flags of the command after its name
+	if len(args) > 0 {
+		command := app.Commands.CommandForName(args[0])
+		if command != nil && len(command.Flags) > 0 {
+			commandFlagSet, n := parseFlags(command.Flags, args[1:])
+			for name, value := range commandFlagSet {
+				flagSet[name] = value
+			}
+
+			flags = append(append(Flags{}, flags...), command.Flags...)
+			args = append([]string{args[0]}, args[1+n:]...)
+		}
+	}
 
 	// Parse the commands
-	action := ParseCommands(app.Action, app.Commands, args[len(flagSet):])
+	action := ParseCommands(app.Action, app.Commands, args)
 
 	// No appropriate action found, so we return ErrIncorrectUsage
 	if action == nil {
@@ -24,6 +41,7 @@ func NewContext(app *App, args []string) (*Context, error) {
 	}
 
 	context := &Context{
+		flags:   flags,
 		flagSet: flagSet,
 	}
 
@@ -41,23 +59,68 @@ func (c *Context) IsSet(name string) bool {
 	return ok
 }
 
-// ParseFlags parses the args and returns a map of flags set.
-func ParseFlags(flags Flags, args []string) map[string]struct{} {
-	flagSet := make(map[string]struct{})
+// String returns the value of the flag with name, or its default if it isn't
+// set.
+func (c *Context) String(name string) string {
+	value, ok := c.flagSet[name]
+	if ok {
+		return value
+	}
+
+	flag := c.flags.FlagForName(name)
+	if flag == nil {
+		return ""
+	}
+
+	return flag.Default
+}
+
+// ParseFlags parses the args and returns a map of flags set to their values.
+// Boolean flags are set to an empty value, and flags that take a value are
+// only set if given one with --name=value or --name value.
+func ParseFlags(flags Flags, args []string) map[string]string {
+	flagSet, _ := parseFlags(flags, args)
+	return flagSet
+}
 
-	for _, arg := range args {
+// parseFlags is like ParseFlags but also returns how many args were consumed.
+// Parsing stops at the first arg that isn't a known flag given correctly.
+func parseFlags(flags Flags, args []string) (map[string]string, int) {
+	flagSet := make(map[string]string)
+
+	i := 0
+	for i < len(args) {
+		arg := args[i]
 		if !strings.HasPrefix(arg, "--") {
 			break
 		}
 
 		alias := strings.TrimLeft(arg, "--")
-		name := flags.NameForAlias(alias)
-		if name != "" {
-			flagSet[name] = struct{}{}
+		alias, value, hasValue := strings.Cut(alias, "=")
+
+		flag := flags.FlagForName(alias)
+		if flag == nil {
+			break
 		}
+
+		consumed := 1
+		if flag.TakesValue && !hasValue {
+			// The value is the next arg, unless it's another flag
+			if i+1 == len(args) || strings.HasPrefix(args[i+1], "--") {
+				break
+			}
+
+			value = args[i+1]
+			consumed = 2
+		} else if !flag.TakesValue && hasValue {
+			break
+		}
+
+		flagSet[flag.Name] = value
+		i += consumed
 	}
 
-	return flagSet
+	return flagSet, i
 }
 
 // ParseCommands parses the args and returns the Action to invoke.
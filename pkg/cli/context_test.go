@@ -126,3 +126,150 @@ func TestParseCommands(t *testing.T) {
 		t.Errorf("Expected %s but got %s", gomakeErr, err)
 	}
 }
+
+func TestParseValueFlags(t *testing.T) {
+	flags := Flags{
+		{
+			Name:       "output",
+			TakesValue: true,
+			Default:    "prefix",
+		},
+		{
+			Name: "timings",
+		},
+	}
+
+	// Test that a flag that takes a value is set with its value
+	flagSet := ParseFlags(flags, []string{"--output=buffered"})
+	if flagSet["output"] != "buffered" {
+		t.Errorf("Expected output to be buffered but got %s", flagSet["output"])
+	}
+
+	// Test that a flag that takes a value is not set without one
+	flagSet = ParseFlags(flags, []string{"--output"})
+	_, ok := flagSet["output"]
+	if ok {
+		t.Errorf("Expected output to be not set")
+	}
+
+	// Test that a boolean flag is not set with a value
+	flagSet = ParseFlags(flags, []string{"--timings=true"})
+	_, ok = flagSet["timings"]
+	if ok {
+		t.Errorf("Expected timings to be not set")
+	}
+
+	// Test that a flag that takes a value is set with the next arg
+	flagSet = ParseFlags(flags, []string{"--output", "buffered", "--timings"})
+	if flagSet["output"] != "buffered" {
+		t.Errorf("Expected output to be buffered but got %s", flagSet["output"])
+	}
+
+	_, ok = flagSet["timings"]
+	if !ok {
+		t.Errorf("Expected timings to be set")
+	}
+
+	// Test that repeated flags are all consumed and the last value wins
+	flagSet, n := parseFlags(flags, []string{"--output=failed", "--output", "buffered", "build"})
+	if flagSet["output"] != "buffered" || n != 3 {
+		t.Errorf("Expected output to be buffered after 3 args but got %s after %d", flagSet["output"], n)
+	}
+}
+
+func TestContextString(t *testing.T) {
+	app := &App{
+		Action: func(ctx *Context) error {
+			return nil
+		},
+		Flags: Flags{
+			{
+				Name:       "output",
+				TakesValue: true,
+				Default:    "prefix",
+			},
+		},
+	}
+
+	// Test that an unset flag returns its default
+	context, err := NewContext(app, []string{})
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	if context.String("output") != "prefix" {
+		t.Errorf("Expected prefix but got %s", context.String("output"))
+	}
+
+	// Test that a set flag returns its value
+	context, err = NewContext(app, []string{"--output=failed"})
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	if context.String("output") != "failed" {
+		t.Errorf("Expected failed but got %s", context.String("output"))
+	}
+
+	// Test that a flag followed by its value is set before a command
+	context, err = NewContext(app, []string{"--output", "buffered"})
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	if context.String("output") != "buffered" {
+		t.Errorf("Expected buffered but got %s", context.String("output"))
+	}
+
+	// Test that a flag without its value returns ErrIncorrectUsage
+	_, err = NewContext(app, []string{"--output"})
+	if err != ErrIncorrectUsage {
+		t.Errorf("Expected %s but got %s", ErrIncorrectUsage, err)
+	}
+}
+
+func TestCommandFlags(t *testing.T) {
+	var actual string
+	app := &App{
+		Commands: Commands{
+			{
+				Name: "import",
+				Flags: Flags{
+					{Name: "file", TakesValue: true, Default: "Makefile"},
+				},
+				Action: func(ctx *Context) error {
+					actual = ctx.String("file")
+					return nil
+				},
+			},
+		},
+	}
+
+	for _, test := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"import"}, "Makefile"},
+		{[]string{"import", "--file=GNUmakefile"}, "GNUmakefile"},
+		{[]string{"import", "--file", "GNUmakefile"}, "GNUmakefile"},
+	} {
+		ctx, err := NewContext(app, test.args)
+		if err != nil {
+			t.Fatalf("Unexpected err: %s", err)
+		}
+
+		err = ctx.Action()
+		if err != nil {
+			t.Errorf("Unexpected err: %s", err)
+		}
+
+		if actual != test.expected {
+			t.Errorf("Expected %s but got %s", test.expected, actual)
+		}
+	}
+
+	_, err := NewContext(app, []string{"import", "--unknown"})
+	if err != ErrIncorrectUsage {
+		t.Errorf("Expected %s but got %v", ErrIncorrectUsage, err)
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	// ExitSuccess is the exit code when the App ran without error.
+	ExitSuccess = 0
+	// ExitFailure is the exit code when the App's action failed.
+	ExitFailure = 1
+	// ExitUsage is the exit code when the App was ran with bad arguments.
+	ExitUsage = 2
+	// ExitInternal is the exit code when the App failed for reasons that
+	// aren't the fault of the action or its arguments.
+	ExitInternal = 3
+	// ExitInterrupted is the exit code when the App was interrupted by a
+	// signal, following the shell convention of 128 + SIGINT.
+	ExitInterrupted = 130
+)
+
+// ExitError is an error that carries the code the program should exit with.
+type ExitError struct {
+	// Code is the exit code.
+	Code int
+	// Err is the underlying error.
+	Err error
+}
+
+// Exit returns err with the code the program should exit with.
+func Exit(err error, code int) error {
+	return &ExitError{
+		Code: code,
+		Err:  err,
+	}
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the code the program should exit with for err. It is the
+// code of the first ExitError in err's chain, ExitUsage for
+// ErrIncorrectUsage, and ExitFailure for any other error.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+
+	if errors.Is(err, ErrIncorrectUsage) {
+		return ExitUsage
+	}
+
+	return ExitFailure
+}
+
+// RunAndExit runs the App with the given args, prints the error it returns, if
+// any, and exits with the error's exit code.
+func (a *App) RunAndExit(args []string) {
+	err := a.Run(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+	}
+
+	os.Exit(ExitCode(err))
+}
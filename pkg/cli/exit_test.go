@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	failure := errors.New("failure")
+	for _, test := range []struct {
+		err      error
+		expected int
+	}{
+		{nil, ExitSuccess},
+		{failure, ExitFailure},
+		{ErrIncorrectUsage, ExitUsage},
+		{fmt.Errorf("wrapped: %w", ErrIncorrectUsage), ExitUsage},
+		{Exit(failure, ExitInterrupted), ExitInterrupted},
+		{fmt.Errorf("wrapped: %w", Exit(failure, ExitInternal)), ExitInternal},
+	} {
+		actual := ExitCode(test.err)
+		if actual != test.expected {
+			t.Errorf("Expected %d for %v but got %d", test.expected, test.err, actual)
+		}
+	}
+}
+
+func TestExitError(t *testing.T) {
+	failure := errors.New("failure")
+	err := Exit(failure, ExitInternal)
+
+	if err.Error() != failure.Error() {
+		t.Errorf("Expected %s but got %s", failure, err)
+	}
+
+	if !errors.Is(err, failure) {
+		t.Errorf("Expected err to wrap %s", failure)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	app := &App{}
+	err := app.Run([]string{"app", "unknown"})
+	if ExitCode(err) != ExitUsage {
+		t.Errorf("Expected exit code %d but got %d", ExitUsage, ExitCode(err))
+	}
+}
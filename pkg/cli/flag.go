@@ -16,7 +16,8 @@ var (
 	}
 )
 
-// Flag is a boolean flag that gets passed down to the action called.
+// Flag is a flag that gets passed down to the action called. Flags are boolean
+// unless TakesValue is set, in which case they are set with --name=value.
 type Flag struct {
 	// Name is the name of this flag.
 	Name string
@@ -24,6 +25,10 @@ type Flag struct {
 	Aliases []string
 	// Description is a brief text of what the flag enables.
 	Description string
+	// TakesValue is whether the flag must be set with a value.
+	TakesValue bool
+	// Default is the value of the flag when it is not set.
+	Default string
 }
 
 // HasName returns true if name matches the flag's name or its aliases.
@@ -41,7 +46,7 @@ func (f *Flag) HasName(name string) bool {
 // Flags is a list of flags.
 type Flags []*Flag
 
-// HasName returns true if any flag in Flags matches name.
+// NameForAlias returns the name of the flag that matches alias.
 func (f Flags) NameForAlias(alias string) string {
 	for _, flag := range f {
 		if flag.HasName(alias) {
@@ -51,3 +56,14 @@ func (f Flags) NameForAlias(alias string) string {
 
 	return ""
 }
+
+// FlagForName returns the flag that matches name or one of its aliases.
+func (f Flags) FlagForName(name string) *Flag {
+	for _, flag := range f {
+		if flag.HasName(name) {
+			return flag
+		}
+	}
+
+	return nil
+}
@@ -48,3 +48,20 @@ func TestNameForAlias(t *testing.T) {
 		t.Errorf("Expected help but got %s", name)
 	}
 }
+
+func TestFlagForName(t *testing.T) {
+	flags := Flags{
+		{
+			Name:    "help",
+			Aliases: []string{"h"},
+		},
+	}
+
+	if flags.FlagForName("unknown") != nil {
+		t.Errorf("Expected no flag for unknown name")
+	}
+
+	if flags.FlagForName("h") != flags[0] {
+		t.Errorf("Expected help flag for its alias")
+	}
+}
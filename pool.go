@@ -0,0 +1,178 @@
+package gomake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrInsufficientCapacity is returned when a rule requires more units of
+	// a pool than it has.
+	ErrInsufficientCapacity = errors.New("insufficient capacity")
+
+	// errNotStarted is returned by an attempt at evaluating a rule that's
+	// cancelled while waiting for its resources, so that the rule is skipped.
+	errNotStarted = errors.New("cancelled before starting")
+
+	// poolMu protects the units in use of every Pool, so that a rule can
+	// acquire all its resources at once. Rules never hold some resources while
+	// waiting for others, so they can't deadlock on each other.
+	poolMu   sync.Mutex
+	poolCond = sync.NewCond(&poolMu)
+)
+
+// Pool is a named resource with a limited capacity, such as a shared test
+// database. Rules that require units of a pool are only evaluated while there
+// are enough units available, limiting how many evaluate at once.
+type Pool struct {
+	// Name is the name of the pool.
+	Name string
+	// Capacity is how many units of the pool there are.
+	Capacity int
+
+	// used is how many units are held by evaluating rules.
+	used int
+	// included is whether the pool has been renamed into a namespace by
+	// Gomakefile.Include.
+	included bool
+}
+
+// NewPool initializes a new named Pool with capacity units.
+func NewPool(name string, capacity int) *Pool {
+	return &Pool{
+		Name:     name,
+		Capacity: capacity,
+	}
+}
+
+// Resource is a number of units of a Pool required by a rule.
+type Resource struct {
+	// Pool is the pool the units are from.
+	Pool *Pool
+	// Units is how many units of the pool are required.
+	Units int
+}
+
+// acquire waits until every resource is available and takes them all, or
+// returns an error if ctx is done first. The returned function releases them.
+func acquire(ctx context.Context, resources []Resource) (func(), error) {
+	// Combine units required of the same pool
+	units := make(map[*Pool]int)
+	for _, resource := range resources {
+		units[resource.Pool] += resource.Units
+	}
+
+	for pool, n := range units {
+		if n > pool.Capacity {
+			return nil, fmt.Errorf("requires %d units of pool %q with capacity %d: %w", n, pool.Name, pool.Capacity, ErrInsufficientCapacity)
+		}
+	}
+
+	// Wake up to give up waiting once ctx is done
+	stop := context.AfterFunc(ctx, func() {
+		poolMu.Lock()
+		defer poolMu.Unlock()
+		poolCond.Broadcast()
+	})
+	defer stop()
+
+	poolMu.Lock()
+	defer poolMu.Unlock()
+
+	for {
+		// Don't take resources for an evaluation that's been cancelled
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+
+		if available(units) {
+			break
+		}
+		poolCond.Wait()
+	}
+
+	for pool, n := range units {
+		pool.used += n
+	}
+
+	release := func() {
+		poolMu.Lock()
+		defer poolMu.Unlock()
+
+		for pool, n := range units {
+			pool.used -= n
+		}
+		poolCond.Broadcast()
+	}
+
+	return release, nil
+}
+
+// available returns whether the units of every pool are available. poolMu
+// must be held.
+func available(units map[*Pool]int) bool {
+	for pool, n := range units {
+		if pool.used+n > pool.Capacity {
+			return false
+		}
+	}
+
+	return true
+}
+
+// heldResources are the resources held by an attempt to evaluate a rule. They
+// can be released and acquired again while the rule waits, and are released
+// for good once the attempt is over.
+type heldResources struct {
+	resources []Resource
+
+	mu      sync.Mutex
+	release func()
+	closed  bool
+}
+
+// acquire waits until the resources are available and holds them, or returns
+// an error if ctx is done first.
+func (h *heldResources) acquire(ctx context.Context) error {
+	release, err := acquire(ctx, h.resources)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// The attempt was abandoned while waiting
+	if h.closed {
+		release()
+		return errors.New("resources released")
+	}
+
+	h.release = release
+	return nil
+}
+
+// suspend releases the resources until they're acquired again.
+func (h *heldResources) suspend() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.suspendLocked()
+}
+
+func (h *heldResources) suspendLocked() {
+	if h.release != nil {
+		h.release()
+		h.release = nil
+	}
+}
+
+// close releases the resources for good.
+func (h *heldResources) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.suspendLocked()
+	h.closed = true
+}
@@ -0,0 +1,175 @@
+package gomake
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrency tracks the most rules evaluating at once.
+type concurrency struct {
+	mu      sync.Mutex
+	current int
+	max     int
+}
+
+func (c *concurrency) rule(target string, dependencies []*Rule) *Rule {
+	return NewRule(target, dependencies, func() error {
+		c.mu.Lock()
+		c.current++
+		if c.current > c.max {
+			c.max = c.current
+		}
+		c.mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		c.mu.Lock()
+		c.current--
+		c.mu.Unlock()
+		return nil
+	})
+}
+
+func TestPool(t *testing.T) {
+	gomakefile := NewGomakefile()
+	database := gomakefile.AddPool("database", 1)
+
+	c := new(concurrency)
+	var rules []*Rule
+	for _, target := range []string{"rule1", "rule2", "rule3"} {
+		rule := c.rule(target, nil)
+		rule.Resources = []Resource{{Pool: database, Units: 1}}
+		rules = append(rules, rule)
+	}
+
+	err := Evaluate(c.rule("root", rules)).Err()
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	if c.max != 1 {
+		t.Errorf("Expected at most 1 rule at once but got %d", c.max)
+	}
+}
+
+func TestPoolMultiple(t *testing.T) {
+	docker := NewPool("docker", 2)
+	database := NewPool("database", 1)
+
+	// Rules requiring both pools in different orders must not deadlock
+	c := new(concurrency)
+	var rules []*Rule
+	for i, target := range []string{"rule1", "rule2", "rule3", "rule4"} {
+		rule := c.rule(target, nil)
+		rule.Resources = []Resource{{Pool: docker, Units: 1}, {Pool: database, Units: 1}}
+		if i%2 == 0 {
+			rule.Resources = []Resource{rule.Resources[1], rule.Resources[0]}
+		}
+		rules = append(rules, rule)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Evaluate(c.rule("root", rules)).Err()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Unexpected err: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timed out waiting for rules to evaluate")
+	}
+
+	if c.max != 1 {
+		t.Errorf("Expected at most 1 rule at once but got %d", c.max)
+	}
+}
+
+func TestPoolInsufficientCapacity(t *testing.T) {
+	pool := NewPool("pool", 1)
+	rule := NewRule("rule", nil, func() error {
+		return nil
+	})
+	rule.Resources = []Resource{{Pool: pool, Units: 2}}
+
+	err := Evaluate(rule).Err()
+	if !errors.Is(err, ErrInsufficientCapacity) {
+		t.Errorf("Expected %s but got %v", ErrInsufficientCapacity, err)
+	}
+}
+
+func TestEvaluateJobs(t *testing.T) {
+	c := new(concurrency)
+	var rules []*Rule
+	for _, target := range []string{"rule1", "rule2", "rule3", "rule4"} {
+		rules = append(rules, c.rule(target, nil))
+	}
+
+	evaluator := &Evaluator{
+		Jobs: 2,
+	}
+
+	err := evaluator.Evaluate(c.rule("root", rules)).Err()
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	if c.max != 2 {
+		t.Errorf("Expected at most 2 rules at once but got %d", c.max)
+	}
+}
+
+func TestPoolCancelled(t *testing.T) {
+	database := NewPool("database", 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Whichever rule gets the pool first cancels the evaluation while the
+	// other waits for it
+	action := func(ctx *Context) error {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+
+		// Hold the pool until the other rule gives up on it
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+
+	rule1 := &Rule{Target: "rule1", Action: action, Resources: []Resource{{Pool: database, Units: 1}}}
+	rule2 := &Rule{Target: "rule2", Action: action, Resources: []Resource{{Pool: database, Units: 1}}}
+
+	results := new(Evaluator).EvaluateAll(ctx, rule1, rule2)
+	skipped := 0
+	for _, result := range results {
+		if result.Status == StatusSkipped {
+			skipped++
+		}
+	}
+
+	if skipped != 1 {
+		t.Errorf("Expected 1 rule to be skipped but got %d", skipped)
+	}
+}
+
+func TestJobsFinalizers(t *testing.T) {
+	c := new(concurrency)
+	rule1 := c.rule("rule1", nil)
+	rule1.Finalizers = []*Rule{c.rule("finalizer", nil)}
+	rule2 := c.rule("rule2", nil)
+
+	evaluator := &Evaluator{Jobs: 1}
+	err := evaluator.EvaluateAll(context.Background(), rule1, rule2).Err()
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	// Finalizers are limited by the same jobs as the rest of the evaluation
+	if c.max != 1 {
+		t.Errorf("Expected at most 1 rule at once but got %d", c.max)
+	}
+}
@@ -0,0 +1,50 @@
+//go:build !unix
+
+package gomake
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var (
+	// interruptSignals are the signals that interrupt an evaluation.
+	interruptSignals = []os.Signal{os.Interrupt}
+
+	// defaultTerminateSignal is sent to commands that are cancelled for any
+	// reason other than a signal.
+	defaultTerminateSignal = os.Kill
+)
+
+// setProcessGroup is a no-op as process groups are only supported on unix.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalProcessGroup sends sig to process, killing it if sig can't be sent.
+func signalProcessGroup(process *os.Process, sig os.Signal) error {
+	err := process.Signal(sig)
+	if err != nil {
+		return process.Kill()
+	}
+
+	return nil
+}
+
+// execBinary runs the binary at path with args and env, as the current
+// process can't be replaced, and exits with its exit code.
+func execBinary(path string, args, env []string) error {
+	cmd := exec.Command(path, args[1:]...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return err
+	}
+
+	os.Exit(cmd.ProcessState.ExitCode())
+	return nil
+}
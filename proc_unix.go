@@ -0,0 +1,40 @@
+//go:build unix
+
+package gomake
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var (
+	// interruptSignals are the signals that interrupt an evaluation.
+	interruptSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+	// defaultTerminateSignal is sent to commands that are cancelled for any
+	// reason other than a signal.
+	defaultTerminateSignal os.Signal = syscall.SIGTERM
+)
+
+// setProcessGroup makes cmd start in its own process group, so that it and
+// any processes it starts can be signalled together.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// signalProcessGroup sends sig to the process group led by process.
+func signalProcessGroup(process *os.Process, sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return process.Signal(sig)
+	}
+
+	return syscall.Kill(-process.Pid, s)
+}
+
+// execBinary replaces the current process with the binary at path run with
+// args and env.
+func execBinary(path string, args, env []string) error {
+	return syscall.Exec(path, args, env)
+}
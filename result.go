@@ -0,0 +1,253 @@
+package gomake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Status is the outcome of evaluating a rule.
+type Status int
+
+const (
+	// StatusSucceeded is the status of a rule that evaluated without error.
+	StatusSucceeded Status = iota
+	// StatusFailed is the status of a rule that evaluated with an error.
+	StatusFailed
+	// StatusSkipped is the status of a rule that wasn't evaluated because a
+	// dependency didn't succeed or the evaluation was cancelled.
+	StatusSkipped
+	// StatusUpToDate is the status of a rule that wasn't evaluated because
+	// nothing has changed since it last succeeded.
+	StatusUpToDate
+)
+
+var statusNames = map[Status]string{
+	StatusSucceeded: "succeeded",
+	StatusFailed:    "failed",
+	StatusSkipped:   "skipped",
+	StatusUpToDate:  "up-to-date",
+}
+
+func (s Status) String() string {
+	name, ok := statusNames[s]
+	if !ok {
+		return fmt.Sprintf("Status(%d)", int(s))
+	}
+
+	return name
+}
+
+// MarshalText encodes the status as its name.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Result is the result of evaluating a rule.
+type Result struct {
+	// Target is the target of the rule.
+	Target string
+	// Rule is the rule that was evaluated, or nil if there is no rule for
+	// Target.
+	Rule *Rule
+	// Status is the outcome of evaluating the rule.
+	Status Status
+	// Err is the error the rule evaluated with if it failed.
+	Err error
+	// Attempts is how many times the rule was evaluated.
+	Attempts int
+	// Finalizer is whether the rule was evaluated as a finalizer, or as a
+	// dependency of one.
+	Finalizer bool
+}
+
+func newResult(rule *Rule, status Status, err error) *Result {
+	return &Result{
+		Target: rule.Target,
+		Rule:   rule,
+		Status: status,
+		Err:    err,
+	}
+}
+
+// succeeded returns whether dependents of the rule can be evaluated.
+func (r *Result) succeeded() bool {
+	return r.Status == StatusSucceeded || r.Status == StatusUpToDate
+}
+
+// started returns whether the rule was evaluated, successfully or not.
+func (r *Result) started() bool {
+	return r.Status == StatusSucceeded || r.Status == StatusFailed
+}
+
+// Results is the list of results from an evaluation, with each rule after its
+// dependencies and the results of finalizers last. Rules needed during the
+// evaluation are listed after the rules that were already being evaluated.
+type Results []*Result
+
+// Lookup returns the result for target, or nil if target wasn't evaluated.
+func (r Results) Lookup(target string) *Result {
+	for _, result := range r {
+		if result.Target == target {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// Err returns a *MultiError of every failed target, or nil if none failed.
+func (r Results) Err() error {
+	var errs []*TargetError
+	for _, result := range r {
+		if result.Status == StatusFailed {
+			errs = append(errs, &TargetError{
+				Target: result.Target,
+				Err:    result.Err,
+			})
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &MultiError{Errors: errs}
+}
+
+// Format is the format results are written in.
+type Format string
+
+const (
+	// FormatText writes a line for every failed target.
+	FormatText Format = "text"
+	// FormatJSON writes a JSON array with an object for every target.
+	FormatJSON Format = "json"
+)
+
+// ParseFormat returns the Format with name.
+func ParseFormat(name string) (Format, error) {
+	format := Format(name)
+	switch format {
+	case FormatText, FormatJSON:
+		return format, nil
+	}
+
+	return "", fmt.Errorf("unknown format %q", name)
+}
+
+type jsonResult struct {
+	Target    string `json:"target"`
+	Status    Status `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Attempts  int    `json:"attempts,omitempty"`
+	Finalizer bool   `json:"finalizer,omitempty"`
+	Group     bool   `json:"group,omitempty"`
+}
+
+// Write writes the results to w in format.
+func (r Results) Write(w io.Writer, format Format) error {
+	switch format {
+	case FormatJSON:
+		results := make([]jsonResult, 0, len(r))
+		for _, result := range r {
+			jr := jsonResult{
+				Target:    result.Target,
+				Status:    result.Status,
+				Attempts:  result.Attempts,
+				Finalizer: result.Finalizer,
+				Group:     result.Rule != nil && result.Rule.IsGroup(),
+			}
+			if result.Err != nil {
+				jr.Error = result.Err.Error()
+			}
+
+			results = append(results, jr)
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	case FormatText, "":
+		for _, result := range r {
+			if result.Status != StatusFailed {
+				continue
+			}
+
+			attempts := ""
+			if result.Attempts > 1 {
+				attempts = fmt.Sprintf(" (after %d attempts)", result.Attempts)
+			}
+
+			finalizer := ""
+			if result.Finalizer {
+				finalizer = " (finalizer)"
+			}
+
+			_, err := fmt.Fprintf(w, "%s%s: %s%s\n", result.Target, finalizer, result.Err, attempts)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// HandleResults displays all the target errs and returns a combined error, or
+// the error from displaying them.
+func HandleResults(results Results) error {
+	err := results.Write(os.Stdout, FormatText)
+	if err != nil {
+		return err
+	}
+
+	return results.Err()
+}
+
+// TargetError is the error a target evaluated with.
+type TargetError struct {
+	// Target is the target that failed.
+	Target string
+	// Err is the error the target evaluated with.
+	Err error
+}
+
+func (e *TargetError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Target, e.Err)
+}
+
+// Unwrap returns the error the target evaluated with.
+func (e *TargetError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError is the errors of every target that failed in an evaluation, with
+// each target after its dependencies. errors.Is and errors.As match against
+// every error in it.
+type MultiError struct {
+	Errors []*TargetError
+}
+
+func (e *MultiError) Error() string {
+	var msgs []string
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the error of every target that failed.
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+
+	return errs
+}
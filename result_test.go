@@ -0,0 +1,116 @@
+package gomake
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestResultsOrder(t *testing.T) {
+	intentional := errors.New("intentional")
+	rule1 := NewRule("rule1", nil, func() error {
+		return intentional
+	})
+	rule2 := NewRule("rule2", nil, func() error {
+		return intentional
+	})
+	rule3 := NewRule("rule3", []*Rule{rule1}, func() error {
+		return nil
+	})
+	rule4 := NewRule("rule4", []*Rule{rule3, rule2}, func() error {
+		return nil
+	})
+
+	results := Evaluate(rule4)
+
+	expected := []string{"rule1", "rule3", "rule2", "rule4"}
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %d results but got %d", len(expected), len(results))
+	}
+
+	for i, result := range results {
+		if result.Target != expected[i] {
+			t.Errorf("Expected %s at %d but got %s", expected[i], i, result.Target)
+		}
+	}
+
+	if results.Lookup("rule3").Status != StatusSkipped {
+		t.Errorf("Expected rule3 to be skipped but got %s", results.Lookup("rule3").Status)
+	}
+
+	var buf bytes.Buffer
+	err := results.Write(&buf, FormatText)
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	expectedText := "rule1: intentional\nrule2: intentional\n"
+	if buf.String() != expectedText {
+		t.Errorf("Expected %q but got %q", expectedText, buf.String())
+	}
+}
+
+func TestResultsErr(t *testing.T) {
+	intentional := errors.New("intentional")
+	cmdErr := &CommandError{Command: "false", ExitCode: 1}
+	results := Results{
+		{Target: "rule1", Status: StatusFailed, Err: intentional},
+		{Target: "rule2", Status: StatusSkipped},
+		{Target: "rule3", Status: StatusFailed, Err: cmdErr},
+	}
+
+	err := results.Err()
+	if !errors.Is(err, intentional) {
+		t.Errorf("Expected err to match %s", intentional)
+	}
+
+	var targetErr *CommandError
+	if !errors.As(err, &targetErr) || targetErr != cmdErr {
+		t.Errorf("Expected err to match CommandError")
+	}
+
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("Expected MultiError but got %T", err)
+	}
+
+	if len(multiErr.Errors) != 2 || multiErr.Errors[1].Target != "rule3" {
+		t.Errorf("Expected errors for rule1 and rule3 but got %s", multiErr)
+	}
+
+	if (Results{{Target: "rule1", Status: StatusSucceeded}}).Err() != nil {
+		t.Errorf("Expected no err from succeeded results")
+	}
+}
+
+func TestResultsWriteJSON(t *testing.T) {
+	results := Results{
+		{Target: "rule1", Status: StatusFailed, Err: errors.New("intentional")},
+		{Target: "rule2", Status: StatusSkipped},
+	}
+
+	var buf bytes.Buffer
+	err := results.Write(&buf, FormatJSON)
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	var actual []map[string]string
+	err = json.Unmarshal(buf.Bytes(), &actual)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	if len(actual) != 2 {
+		t.Fatalf("Expected 2 results but got %d", len(actual))
+	}
+
+	if actual[0]["status"] != "failed" || actual[0]["error"] != "intentional" {
+		t.Errorf("Unexpected result %v", actual[0])
+	}
+
+	if actual[1]["target"] != "rule2" || actual[1]["status"] != "skipped" {
+		t.Errorf("Unexpected result %v", actual[1])
+	}
+}
@@ -0,0 +1,61 @@
+package gomake
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// maxBackoff is the longest a RetryPolicy's backoff doubles up to.
+const maxBackoff = time.Hour
+
+// RetryPolicy is how a rule that fails is evaluated again.
+type RetryPolicy struct {
+	// Attempts is the most times the rule is evaluated, including the first.
+	Attempts int
+	// Backoff is how long to wait before the second attempt, doubling before
+	// every attempt after that up to an hour, or Backoff if it's longer.
+	Backoff time.Duration
+	// Retryable returns whether the rule should be evaluated again after
+	// failing with err. If nil, every error is retried. Panics are never
+	// retried.
+	Retryable func(err error) bool
+}
+
+// next returns how long to wait before the attempt after the one that failed
+// with err, and whether there should be another attempt at all.
+func (p *RetryPolicy) next(attempt int, err error) (time.Duration, bool) {
+	if p == nil || err == nil || attempt >= p.Attempts {
+		return 0, false
+	}
+
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		return 0, false
+	}
+
+	if p.Retryable != nil && !p.Retryable(err) {
+		return 0, false
+	}
+
+	// Stop doubling before the backoff gets too long or overflows
+	backoff := p.Backoff
+	for i := 1; i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return max(p.Backoff, min(backoff, maxBackoff)), true
+}
+
+// sleep waits for d and returns true, or returns false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
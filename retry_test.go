@@ -0,0 +1,132 @@
+package gomake
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyNext(t *testing.T) {
+	intentional := errors.New("intentional")
+	policy := &RetryPolicy{
+		Attempts: 3,
+		Backoff:  time.Second,
+	}
+
+	backoff, ok := policy.next(1, intentional)
+	if !ok || backoff != time.Second {
+		t.Errorf("Expected retry after 1s but got %t after %s", ok, backoff)
+	}
+
+	backoff, ok = policy.next(2, intentional)
+	if !ok || backoff != 2*time.Second {
+		t.Errorf("Expected retry after 2s but got %t after %s", ok, backoff)
+	}
+
+	_, ok = policy.next(3, intentional)
+	if ok {
+		t.Errorf("Expected no retry after last attempt")
+	}
+
+	_, ok = policy.next(1, nil)
+	if ok {
+		t.Errorf("Expected no retry after success")
+	}
+
+	var nilPolicy *RetryPolicy
+	_, ok = nilPolicy.next(1, intentional)
+	if ok {
+		t.Errorf("Expected no retry without a policy")
+	}
+}
+
+func TestRetryPolicyNextMaxBackoff(t *testing.T) {
+	intentional := errors.New("intentional")
+	policy := &RetryPolicy{
+		Attempts: 100,
+		Backoff:  time.Second,
+	}
+
+	backoff, ok := policy.next(99, intentional)
+	if !ok || backoff != maxBackoff {
+		t.Errorf("Expected retry after %s but got %t after %s", maxBackoff, ok, backoff)
+	}
+
+	// Backoffs longer than the maximum are kept as they are
+	policy.Backoff = 2 * maxBackoff
+	backoff, ok = policy.next(99, intentional)
+	if !ok || backoff != 2*maxBackoff {
+		t.Errorf("Expected retry after %s but got %t after %s", 2*maxBackoff, ok, backoff)
+	}
+}
+
+func TestEvaluateRetry(t *testing.T) {
+	flaky := errors.New("flaky")
+	failures := 2
+	rule := NewRule("flaky", nil, func() error {
+		if failures > 0 {
+			failures--
+			return flaky
+		}
+		return nil
+	})
+	rule.Retry = &RetryPolicy{
+		Attempts: 3,
+		Backoff:  time.Millisecond,
+	}
+
+	var (
+		mu       sync.Mutex
+		attempts []int
+	)
+	var buf bytes.Buffer
+	evaluator := &Evaluator{
+		Stderr: &buf,
+		Observers: []Observer{
+			ObserverFunc(func(event Event) {
+				mu.Lock()
+				defer mu.Unlock()
+				if event.Kind == EventFinished {
+					attempts = append(attempts, event.Attempt)
+				}
+			}),
+		},
+	}
+
+	result := evaluator.Evaluate(rule).Lookup("flaky")
+	if result.Err != nil {
+		t.Errorf("Unexpected err: %s", result.Err)
+	}
+
+	if result.Attempts != 3 {
+		t.Errorf("Expected 3 attempts but got %d", result.Attempts)
+	}
+
+	if len(attempts) != 3 || attempts[0] != 1 || attempts[2] != 3 {
+		t.Errorf("Expected observers to see attempts 1 to 3 but got %v", attempts)
+	}
+}
+
+func TestEvaluateRetryable(t *testing.T) {
+	permanent := errors.New("permanent")
+	rule := NewRule("permanent", nil, func() error {
+		return permanent
+	})
+	rule.Retry = &RetryPolicy{
+		Attempts: 3,
+		Retryable: func(err error) bool {
+			return !errors.Is(err, permanent)
+		},
+	}
+
+	result := Evaluate(rule).Lookup("permanent")
+	if result.Err != permanent {
+		t.Errorf("Expected %s but got %s", permanent, result.Err)
+	}
+
+	if result.Attempts != 1 {
+		t.Errorf("Expected 1 attempt but got %d", result.Attempts)
+	}
+}
@@ -1,9 +1,14 @@
 package gomake
 
 import (
-	"container/list"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
 	"sync"
+	"time"
 )
 
 // Rule is a node in a dependency graph.
@@ -12,10 +17,81 @@ type Rule struct {
 	Target string
 	// Description is an optional field describing the rule.
 	Description string
+	// Tags are labels for selecting rules by kind, such as "lint" or "slow".
+	Tags []string
+	// Params are the parameters of a rule expanded from a Matrix.
+	Params Params
 	// Dependencies is a list of rules that must be evaluated before this.
 	Dependencies []*Rule
+	// SoftDependencies is a list of rules that are evaluated before this like
+	// Dependencies, but this rule is still evaluated if they don't succeed.
+	SoftDependencies []*Rule
+	// OrderOnly is a list of rules that must finish before this if they are
+	// being evaluated anyway, but aren't evaluated just because of this.
+	OrderOnly []*Rule
+	// Inputs are the files the rule reads, as glob patterns or directories
+	// ending in "/..." to match every file beneath them. They are watched for
+	// changes when watching the rule or its dependents.
+	Inputs []string
+	// Outputs are the files the rule writes. If they all exist and are newer
+	// than every input and the Outputs of its dependencies, every input
+	// matches a file and no dependency was evaluated again, the rule is up to
+	// date and isn't evaluated.
+	Outputs []string
 	// Evaluate is the arbitrary function to evaluate the rule.
 	Evaluate func() error
+	// Action is like Evaluate but is given the Context the rule is evaluated
+	// in. If set, it is called instead of Evaluate. A rule with neither is a
+	// group, which succeeds once its dependencies do.
+	Action Action
+	// Timeout is how long the rule may take to evaluate before its Context is
+	// cancelled and it fails with a *TimeoutError. If zero, the Evaluator's
+	// Timeout is used. Each attempt at evaluating the rule has its own timeout.
+	// An attempt abandoned after the grace period isn't retried, and holds its
+	// Resources until it returns.
+	Timeout time.Duration
+	// Retry is how the rule is evaluated again if it fails. If nil, the rule
+	// is only attempted once.
+	Retry *RetryPolicy
+	// Resources are the units of pools the rule holds while evaluating. The
+	// rule waits until all of them are available before it's evaluated.
+	Resources []Resource
+	// Finalizers are rules evaluated after this rule finishes, whether it
+	// succeeds, fails or the evaluation is cancelled while it's running. They
+	// aren't evaluated if the rule never started because it was skipped or up
+	// to date. Finalizers and their dependencies are evaluated on their own,
+	// and cancelling the evaluation doesn't cancel them.
+	Finalizers []*Rule
+
+	// included is whether the rule has been renamed into a namespace by
+	// Gomakefile.Include.
+	included bool
+}
+
+// TimeoutError is returned when a rule takes longer than its timeout.
+type TimeoutError struct {
+	// Target is the target of the rule that timed out.
+	Target string
+	// Timeout is how long the rule was allowed to take.
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.Timeout)
+}
+
+// PanicError is returned when a rule panics while evaluating.
+type PanicError struct {
+	// Target is the target of the rule that panicked.
+	Target string
+	// Value is the value the rule panicked with.
+	Value any
+	// Stack is the stack trace of the goroutine that panicked.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
 }
 
 // NewRule initializes a new named Rule with its direct dependencies and
@@ -28,101 +104,589 @@ func NewRule(target string, dependencies []*Rule, evaluate func() error) *Rule {
 	}
 }
 
+// NewGroup initializes a new group Rule with a target that depends on members.
+func NewGroup(target string, members ...*Rule) *Rule {
+	return NewRule(target, members, nil)
+}
+
+// Evaluator evaluates rules and their dependencies.
+type Evaluator struct {
+	// Observers are notified as each rule starts and finishes evaluating.
+	Observers []Observer
+	// Output is how the output rule Actions write to their Context is shown,
+	// defaulting to OutputPrefix.
+	Output OutputMode
+	// Stdout is where rule output is written, defaulting to os.Stdout.
+	Stdout io.Writer
+	// Stderr is where rule errors are written, defaulting to os.Stderr.
+	Stderr io.Writer
+	// DryRun is whether rules only print the commands they would run. Rules
+	// with an Evaluate func instead of an Action can't tell they are in a dry
+	// run, so they aren't evaluated at all.
+	DryRun bool
+	// Silent is whether rules run commands without printing them first.
+	Silent bool
+	// GracePeriod is how long commands are given to exit after the evaluation
+	// is cancelled before they are killed, defaulting to DefaultGracePeriod.
+	GracePeriod time.Duration
+	// Timeout is how long each rule without its own Timeout may take to
+	// evaluate. If zero, rules have no time limit.
+	Timeout time.Duration
+	// Jobs is how many rules may evaluate at once. If zero, there is no limit
+	// beyond the Resources required by each rule.
+	Jobs int
+
+	// outputMu serializes writes to Stdout and Stderr.
+	outputMu sync.Mutex
+}
+
+// Evaluate evaluates root rule's dependency graph with a default Evaluator.
+func Evaluate(root *Rule) Results {
+	return new(Evaluator).Evaluate(root)
+}
+
 // Evaluate traverses root rule's dependency graph and creates goroutines for
 // all rules it visit. Each goroutine will wait for its dependencies to be
-// evaluated before evaluating itself, but if any dependency evaluates with an
-// error, it will exit early.
-func Evaluate(root *Rule) map[string]error {
-	// Traverse dependency graph and create goroutines for all rules
-	resultChs := evaluateAllRules(root)
+// evaluated before evaluating itself, but if any dependency doesn't succeed,
+// it will exit early and be skipped. The results are in topological order,
+// so every rule comes after its dependencies.
+func (e *Evaluator) Evaluate(root *Rule) Results {
+	return e.EvaluateContext(context.Background(), root)
+}
+
+// EvaluateContext is like Evaluate but rules are evaluated in ctx. Once ctx is
+// done, no more rules are started and the commands of the rules that are
+// evaluating are signalled to exit.
+func (e *Evaluator) EvaluateContext(ctx context.Context, root *Rule) Results {
+	return e.evaluate(ctx, []*Rule{root}, nil)
+}
 
-	// Build results map
-	results := make(map[string]error)
-	for rule, err := range resultChs {
-		results[rule.Target] = <-err
+// EvaluateAll is like EvaluateContext but evaluates the union of the
+// dependency graphs of roots, so rules they share are only evaluated once.
+func (e *Evaluator) EvaluateAll(ctx context.Context, roots ...*Rule) Results {
+	return e.evaluate(ctx, roots, nil)
+}
+
+// evaluate evaluates the dependency graphs of roots in ctx, skipping rules that
+// upToDate returns true for.
+func (e *Evaluator) evaluate(ctx context.Context, roots []*Rule, upToDate func(rule *Rule) bool) Results {
+	return e.evaluateJobs(ctx, roots, upToDate, e.newJobs())
+}
+
+// newJobs returns a pool limiting how many rules evaluate at once to Jobs, or
+// nil if it's unlimited.
+func (e *Evaluator) newJobs() *Pool {
+	if e.Jobs <= 0 {
+		return nil
+	}
+
+	return NewPool("jobs", e.Jobs)
+}
+
+// evaluateJobs is like evaluate but limits how many rules evaluate at once
+// with jobs, if set, so that it can be shared with other evaluations.
+func (e *Evaluator) evaluateJobs(ctx context.Context, roots []*Rule, upToDate func(rule *Rule) bool, jobs *Pool) Results {
+	if len(roots) == 0 {
+		return nil
+	}
+
+	// Rules in a cycle would wait on each other forever
+	sorted, err := sortTopologically(roots...)
+	if err != nil {
+		return Results{newResult(roots[0], StatusFailed, err)}
+	}
+
+	ev := &evaluation{
+		Evaluator: e,
+		ctx:       ctx,
+		states:    make(map[*Rule]*ruleState),
+		needs:     make(map[*Rule][]*Rule),
+		upToDate:  upToDate,
+		jobs:      jobs,
+	}
+
+	// Create goroutines for all rules and wait for them, including rules
+	// needed at runtime
+	ev.evaluateAllRules(sorted)
+
+	// Build results in topological order, followed by the results of
+	// finalizers
+	var results, finalized Results
+	for _, rule := range ev.order {
+		results = append(results, ev.states[rule].result)
+		finalized = append(finalized, ev.states[rule].finalized...)
+	}
+
+	return append(results, finalized...)
+}
+
+// finalize evaluates each of finalizers in a context that isn't cancelled with
+// ctx, so that they run even if the evaluation is interrupted. They share jobs
+// with the evaluation they finalize.
+func (e *Evaluator) finalize(ctx context.Context, finalizers []*Rule, jobs *Pool) Results {
+	var results Results
+	for _, finalizer := range finalizers {
+		for _, result := range e.evaluateJobs(context.WithoutCancel(ctx), []*Rule{finalizer}, nil, jobs) {
+			result.Finalizer = true
+			results = append(results, result)
+		}
 	}
 
 	return results
 }
 
-func evaluateAllRules(root *Rule) map[*Rule]chan error {
-	// Waits for all goroutines in rule's dependency graph to finish evaluating
-	var wg sync.WaitGroup
+// evaluation is the state of a single evaluation of a dependency graph.
+type evaluation struct {
+	*Evaluator
 
-	resultChs := make(map[*Rule]chan error)
+	ctx context.Context
 
-	// Stall rule evaluation until all rules have been visited
-	start := make(chan struct{})
+	// mu protects states, order and needs, which grow as rules need more
+	// dependencies during the evaluation.
+	mu     sync.Mutex
+	states map[*Rule]*ruleState
+	// order is the rules being evaluated, with each rule after the rules
+	// that must finish before it.
+	order []*Rule
+	// needs are the dependencies each rule needed during the evaluation.
+	needs map[*Rule][]*Rule
+	// wg waits for every rule to finish evaluating.
+	wg sync.WaitGroup
 
-	queue := list.New()
-	queue.PushBack(root)
-	for elem := queue.Front(); elem != nil; elem = elem.Next() {
-		rule := elem.Value.(*Rule)
+	// upToDate returns whether a rule can be skipped, if set.
+	upToDate func(rule *Rule) bool
+	// jobs limits how many rules evaluate at once, if set.
+	jobs *Pool
+}
 
-		// Skip if visited already
-		_, ok := resultChs[rule]
+// ruleState is the state of a rule in a single evaluation.
+type ruleState struct {
+	result *Result
+	// finalized are the results of the rule's finalizers
+	finalized Results
+	// value is the value produced by the rule if it's a ValueRule that
+	// succeeded
+	value any
+	// done is closed once the rule has a result
+	done chan struct{}
+}
+
+func (s *ruleState) finish(result *Result) {
+	s.result = result
+	close(s.done)
+}
+
+func (ev *evaluation) evaluateAllRules(sorted []*Rule) {
+	ev.mu.Lock()
+	ev.schedule(sorted)
+	ev.mu.Unlock()
+
+	ev.wg.Wait()
+}
+
+// schedule creates a goroutine to evaluate each of sorted that isn't already
+// being evaluated. Rules must be sorted topologically. ev.mu must be held.
+func (ev *evaluation) schedule(sorted []*Rule) {
+	for _, rule := range sorted {
+		// Skip if scheduled already
+		_, ok := ev.states[rule]
 		if ok {
 			continue
 		}
 
-		// Mark as visited and create result channel
-		resultChs[rule] = make(chan error, 1)
-
-		// Add dependencies to rules to visit
-		for _, dependency := range rule.Dependencies {
-			queue.PushBack(dependency)
+		ev.states[rule] = &ruleState{
+			done: make(chan struct{}),
 		}
+		ev.order = append(ev.order, rule)
 
-		wg.Add(1)
+		ev.wg.Add(1)
 		go func(rule *Rule) {
-			defer wg.Done()
-			<-start
-			evaluateRule(rule, resultChs)
+			defer ev.wg.Done()
+			ev.evaluateRule(rule)
+
+			// Finalizers clean up after the rule, so there's nothing for them
+			// to do if it never started
+			state := ev.state(rule)
+			if state.result.started() {
+				state.finalized = ev.finalize(ev.ctx, rule.Finalizers, ev.jobs)
+			}
 		}(rule)
 	}
+}
 
-	// Rules can begin evaluating
-	close(start)
-	wg.Wait()
-	return resultChs
+// state returns the state of rule, or nil if it isn't being evaluated.
+func (ev *evaluation) state(rule *Rule) *ruleState {
+	ev.mu.Lock()
+	defer ev.mu.Unlock()
+	return ev.states[rule]
 }
 
-func evaluateRule(rule *Rule, resultChs map[*Rule]chan error) {
-	ruleCh := resultChs[rule]
+func (ev *evaluation) evaluateRule(rule *Rule) {
+	state := ev.state(rule)
 
 	// Wait for dependencies to be evaluated
+	rebuilt := false
 	for _, dependency := range rule.Dependencies {
-		dependencyCh := resultChs[dependency]
-
-		// Grab a copy and return it to the channel so that all its dependents
-		// can take a look at its result
-		err := <-dependencyCh
-		dependencyCh <- err
+		dependencyState := ev.state(dependency)
+		<-dependencyState.done
 
-		// If any dependency returns err, exit early
-		if err != nil {
-			ruleCh <- nil
+		// If any dependency doesn't succeed, exit early
+		if !dependencyState.result.succeeded() {
+			state.finish(newResult(rule, StatusSkipped, nil))
 			return
 		}
+
+		if dependencyState.result.Status != StatusUpToDate {
+			rebuilt = true
+		}
+	}
+
+	// Wait for soft and order-only dependencies regardless of their results
+	waited := append(append([]*Rule{}, rule.SoftDependencies...), rule.OrderOnly...)
+	for _, dependency := range waited {
+		dependencyState := ev.state(dependency)
+		if dependencyState != nil {
+			<-dependencyState.done
+		}
+	}
+
+	// Groups have nothing to evaluate, so their status is derived from their
+	// members
+	if rule.IsGroup() {
+		state.finish(newResult(rule, ev.groupStatus(rule), nil))
+		return
+	}
+
+	if ev.upToDate != nil && ev.upToDate(rule) {
+		state.finish(newResult(rule, StatusUpToDate, nil))
+		return
 	}
 
-	err := rule.Evaluate()
-	ruleCh <- err
+	if !rebuilt && rule.outputsUpToDate() {
+		state.finish(newResult(rule, StatusUpToDate, nil))
+		return
+	}
+
+	// Don't start evaluating if the evaluation has been cancelled
+	if ev.ctx.Err() != nil {
+		state.finish(newResult(rule, StatusSkipped, nil))
+		return
+	}
+
+	output := ev.newRuleOutput(rule)
+
+	var (
+		err     error
+		attempt int
+	)
+	for {
+		attempt++
+		abandoned, attemptErr := ev.evaluateAttempt(rule, output, attempt)
+
+		// The evaluation was cancelled before the attempt could start
+		if errors.Is(attemptErr, errNotStarted) {
+			attempt--
+			break
+		}
+
+		err = attemptErr
+
+		// An abandoned attempt may still be running, so another attempt
+		// could run alongside it
+		backoff, ok := rule.Retry.next(attempt, err)
+		if !ok || abandoned || ev.ctx.Err() != nil {
+			break
+		}
+
+		fmt.Fprintf(output.Stderr(), "attempt %d of %d failed, retrying in %s: %s\n", attempt, rule.Retry.Attempts, backoff, err)
+		if !sleep(ev.ctx, backoff) {
+			break
+		}
+	}
+	output.Close(err)
+
+	if attempt == 0 {
+		state.finish(newResult(rule, StatusSkipped, nil))
+		return
+	}
+
+	status := StatusSucceeded
+	if err != nil {
+		status = StatusFailed
+	}
+
+	result := newResult(rule, status, err)
+	result.Attempts = attempt
+	state.finish(result)
 }
 
-// HandleResults displays all the target errs and returns a combined error.
-func HandleResults(results map[string]error) error {
-	var errs []error
-	for target, err := range results {
+// evaluateAttempt evaluates rule once, writing to output, and returns whether
+// the attempt was abandoned after timing out, in which case its resources are
+// held until it returns.
+func (ev *evaluation) evaluateAttempt(rule *Rule, output *ruleOutput, attempt int) (bool, error) {
+	resources := rule.Resources
+	if ev.jobs != nil {
+		resources = append([]Resource{{Pool: ev.jobs, Units: 1}}, resources...)
+	}
+
+	// Only start the clock on timeouts once the rule can be evaluated
+	held := &heldResources{resources: resources}
+	err := held.acquire(ev.ctx)
+	if err != nil {
+		if ev.ctx.Err() != nil {
+			return false, errNotStarted
+		}
+		return false, err
+	}
+
+	parent, cancel := ev.withTimeout(rule)
+	defer cancel()
+
+	ctx := &Context{
+		Context:     parent,
+		Rule:        rule,
+		Stdout:      output.Stdout(),
+		Stderr:      output.Stderr(),
+		DryRun:      ev.DryRun,
+		Silent:      ev.Silent,
+		GracePeriod: ev.GracePeriod,
+		evaluation:  ev,
+		held:        held,
+	}
+
+	ev.notify(Event{Kind: EventStarted, Rule: rule, Time: time.Now(), Attempt: attempt})
+	running, err := rule.evaluateWithTimeout(ctx)
+	ev.notify(Event{Kind: EventFinished, Rule: rule, Time: time.Now(), Attempt: attempt, Err: err})
+
+	// Keep other rules from using the resources until the abandoned rule
+	// actually returns
+	if running != nil {
+		go func() {
+			<-running
+			held.close()
+		}()
+		return true, err
+	}
+	held.close()
+
+	// The rule has returned if it succeeded, so its value can't change
+	if err == nil {
+		ev.state(rule).value = ctx.value
+	}
+
+	return false, err
+}
+
+// pulled returns the rules that are evaluated because this rule is.
+func (r *Rule) pulled() []*Rule {
+	return append(append([]*Rule{}, r.Dependencies...), r.SoftDependencies...)
+}
+
+// prerequisites returns the rules that must finish before this one, if they
+// are in graph.
+func (r *Rule) prerequisites(graph map[*Rule]bool) []*Rule {
+	prerequisites := r.pulled()
+	for _, dependency := range r.OrderOnly {
+		if graph[dependency] {
+			prerequisites = append(prerequisites, dependency)
+		}
+	}
+
+	return prerequisites
+}
+
+// withTimeout returns the context for rule to be evaluated in, which times out
+// after the rule's Timeout, or the Evaluator's if the rule has none.
+func (ev *evaluation) withTimeout(rule *Rule) (context.Context, context.CancelFunc) {
+	timeout := rule.Timeout
+	if timeout == 0 {
+		timeout = ev.Timeout
+	}
+
+	if timeout <= 0 {
+		return context.WithCancel(ev.ctx)
+	}
+
+	return context.WithTimeoutCause(ev.ctx, timeout, &TimeoutError{
+		Target:  rule.Target,
+		Timeout: timeout,
+	})
+}
+
+// evaluateWithTimeout evaluates the rule and returns a *TimeoutError if ctx
+// times out. Once timed out, the rule has the grace period to return before
+// it is abandoned, in case it isn't running commands that can be killed. If
+// it's abandoned, the returned channel receives once it does return.
+func (r *Rule) evaluateWithTimeout(ctx *Context) (<-chan error, error) {
+	done := make(chan error, 1)
+	go func() {
+		done <- r.evaluate(ctx)
+	}()
+
+	var (
+		err     error
+		running <-chan error
+	)
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		var timeoutErr *TimeoutError
+		if !errors.As(context.Cause(ctx), &timeoutErr) {
+			// Cancelled for some other reason, so wait for the rule to stop
+			err = <-done
+			break
+		}
+
+		select {
+		case err = <-done:
+		case <-time.After(ctx.gracePeriod()):
+			running = done
+		}
+	}
+
+	// Report the timeout rather than the error from being killed by it
+	var timeoutErr *TimeoutError
+	if errors.As(context.Cause(ctx), &timeoutErr) {
+		return running, timeoutErr
+	}
+
+	return running, err
+}
+
+// groupStatus returns the status of a group whose members all succeeded, which
+// is up to date if they all are.
+func (ev *evaluation) groupStatus(rule *Rule) Status {
+	if len(rule.Dependencies) == 0 {
+		return StatusSucceeded
+	}
+
+	for _, member := range rule.Dependencies {
+		if ev.state(member).result.Status != StatusUpToDate {
+			return StatusSucceeded
+		}
+	}
+
+	return StatusUpToDate
+}
+
+// outputsUpToDate returns whether the rule has outputs, which all exist and
+// are newer than its inputs and the outputs of its dependencies. Inputs that
+// don't match any file make the rule out of date, as they may be made by
+// evaluating it.
+func (r *Rule) outputsUpToDate() bool {
+	if len(r.Outputs) == 0 {
+		return false
+	}
+
+	var inputs []string
+	for _, input := range r.Inputs {
+		matches, err := expandInputs([]string{input})
+		if err != nil || len(matches) == 0 {
+			return false
+		}
+		inputs = append(inputs, matches...)
+	}
+
+	// Dependencies may have made their outputs in an earlier run that this
+	// rule failed in
+	inputs = append(inputs, r.dependencyOutputs()...)
+
+	var newest time.Time
+	for _, input := range inputs {
+		info, err := os.Stat(input)
 		if err != nil {
-			fmt.Printf("%s: %s", target, err)
-			errs = append(errs, err)
+			return false
+		}
+
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
 		}
 	}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("%s", errs)
+	for _, output := range r.Outputs {
+		info, err := os.Stat(output)
+		if err != nil || info.ModTime().Before(newest) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// dependencyOutputs returns the Outputs of the rule's dependencies, including
+// the members of groups it depends on.
+func (r *Rule) dependencyOutputs() []string {
+	var outputs []string
+	for _, dependency := range r.Dependencies {
+		outputs = append(outputs, dependency.Outputs...)
+		if dependency.IsGroup() {
+			outputs = append(outputs, dependency.dependencyOutputs()...)
+		}
+	}
+
+	return outputs
+}
+
+// HasTag returns whether the rule is tagged with tag.
+func (r *Rule) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if t == tag {
+			return true
+		}
 	}
 
-	return nil
+	return false
+}
+
+// IsGroup returns whether the rule is a group, which has nothing to evaluate
+// and only depends on other rules.
+func (r *Rule) IsGroup() bool {
+	return r.Evaluate == nil && r.Action == nil
+}
+
+// evaluate evaluates the rule, recovering from a panic as a *PanicError.
+func (r *Rule) evaluate(ctx *Context) (err error) {
+	defer func() {
+		value := recover()
+		if value != nil {
+			err = &PanicError{
+				Target: r.Target,
+				Value:  value,
+				Stack:  debug.Stack(),
+			}
+		}
+	}()
+
+	if r.Action != nil {
+		return r.Action(ctx)
+	}
+
+	if ctx.DryRun {
+		return nil
+	}
+
+	return r.Evaluate()
+}
+
+func (e *Evaluator) newRuleOutput(rule *Rule) *ruleOutput {
+	mode := e.Output
+	if mode == "" {
+		mode = OutputPrefix
+	}
+
+	var stdout, stderr io.Writer = os.Stdout, os.Stderr
+	if e.Stdout != nil {
+		stdout = e.Stdout
+	}
+	if e.Stderr != nil {
+		stderr = e.Stderr
+	}
+
+	return newRuleOutput(&e.outputMu, mode, rule.Target, stdout, stderr)
+}
+
+func (e *Evaluator) notify(event Event) {
+	for _, observer := range e.Observers {
+		observer.Observe(event)
+	}
 }
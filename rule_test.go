@@ -2,10 +2,14 @@ package gomake
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestEvaluate(t *testing.T) {
@@ -41,7 +45,7 @@ func TestEvaluate(t *testing.T) {
 
 	err := HandleResults(Evaluate(rule4))
 	if err != nil {
-		t.Errorf("Failed to evaluate", err)
+		t.Errorf("Failed to evaluate: %s", err)
 	}
 
 	expected := []byte{'1', '1', '2', '3'}
@@ -89,13 +93,13 @@ func TestEvaluateErr(t *testing.T) {
 	})
 
 	results := Evaluate(rule6)
-	err, ok := results["error"]
-	if !ok {
-		t.Errorf("No result for target error")
+	result := results.Lookup("error")
+	if result == nil {
+		t.Fatalf("No result for target error")
 	}
 
-	if err != intentional {
-		t.Errorf("Expected %s but got %s", intentional, err)
+	if result.Err != intentional {
+		t.Errorf("Expected %s but got %s", intentional, result.Err)
 	}
 
 	expected := []byte{'1', '2'}
@@ -105,8 +109,8 @@ func TestEvaluateErr(t *testing.T) {
 }
 
 func TestHandleResults(t *testing.T) {
-	results := map[string]error{
-		"target": nil,
+	results := Results{
+		{Target: "target", Status: StatusSucceeded},
 	}
 
 	err := HandleResults(results)
@@ -115,9 +119,9 @@ func TestHandleResults(t *testing.T) {
 	}
 
 	expected := errors.New("expected")
-	results = map[string]error{
-		"target1": nil,
-		"target2": expected,
+	results = Results{
+		{Target: "target1", Status: StatusSucceeded},
+		{Target: "target2", Status: StatusFailed, Err: expected},
 	}
 
 	err = HandleResults(results)
@@ -129,3 +133,379 @@ func TestHandleResults(t *testing.T) {
 		t.Errorf("Expected %s in error message but got %s", expected, err)
 	}
 }
+
+func TestEvaluateTimeout(t *testing.T) {
+	rule := NewRule("sleep", nil, nil)
+	rule.Timeout = 100 * time.Millisecond
+	rule.Action = func(ctx *Context) error {
+		return Run(ctx, "sleep", "10")
+	}
+
+	var buf bytes.Buffer
+	evaluator := &Evaluator{
+		Stdout:      &buf,
+		Stderr:      &buf,
+		GracePeriod: 100 * time.Millisecond,
+	}
+
+	begin := time.Now()
+	err := evaluator.Evaluate(rule).Err()
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("Expected TimeoutError but got %v", err)
+	}
+
+	if timeoutErr.Target != "sleep" || timeoutErr.Timeout != rule.Timeout {
+		t.Errorf("Unexpected TimeoutError %#v", timeoutErr)
+	}
+
+	if time.Since(begin) > 5*time.Second {
+		t.Errorf("Expected sleep to be killed after timing out")
+	}
+}
+
+func TestEvaluateTimeoutAbandoned(t *testing.T) {
+	// The rule ignores its context, so it is abandoned after the grace period
+	block := make(chan struct{})
+	defer close(block)
+
+	rule := NewRule("block", nil, func() error {
+		<-block
+		return nil
+	})
+
+	evaluator := &Evaluator{
+		Timeout:     100 * time.Millisecond,
+		GracePeriod: 100 * time.Millisecond,
+	}
+
+	var timeoutErr *TimeoutError
+	err := evaluator.Evaluate(rule).Err()
+	if !errors.As(err, &timeoutErr) {
+		t.Errorf("Expected TimeoutError but got %v", err)
+	}
+}
+
+func TestEvaluateTimeoutAbandonedResources(t *testing.T) {
+	database := NewPool("database", 1)
+
+	// The rule ignores its context, so its first attempt is abandoned while
+	// still using the database
+	var (
+		mu       sync.Mutex
+		attempts int
+	)
+	block := make(chan struct{})
+	rule := NewRule("block", nil, func() error {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+
+		<-block
+		return nil
+	})
+	rule.Resources = []Resource{{Pool: database, Units: 1}}
+	rule.Retry = &RetryPolicy{Attempts: 3}
+
+	evaluator := &Evaluator{
+		Timeout:     20 * time.Millisecond,
+		GracePeriod: 20 * time.Millisecond,
+	}
+
+	result := evaluator.Evaluate(rule).Lookup("block")
+	mu.Lock()
+	if result.Attempts != 1 || attempts != 1 {
+		t.Errorf("Expected abandoned attempt not to be retried but got %d attempts", attempts)
+	}
+	mu.Unlock()
+
+	// The database is only released once the abandoned attempt returns
+	other := &Rule{Target: "other", Action: func(ctx *Context) error { return nil }}
+	other.Resources = []Resource{{Pool: database, Units: 1}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	result = new(Evaluator).EvaluateContext(ctx, other).Lookup("other")
+	if result.Status != StatusSkipped {
+		t.Errorf("Expected other to wait for the database but got %s", result.Status)
+	}
+
+	close(block)
+	err := new(Evaluator).Evaluate(other).Err()
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+}
+
+func TestEvaluatePanic(t *testing.T) {
+	rule1 := NewRule("panic", nil, func() error {
+		panic("intentional")
+	})
+	rule2 := NewRule("dependent", []*Rule{rule1}, func() error {
+		return nil
+	})
+	rule3 := NewRule("independent", nil, func() error {
+		return nil
+	})
+	rule4 := NewRule("root", []*Rule{rule2, rule3}, func() error {
+		return nil
+	})
+
+	results := Evaluate(rule4)
+
+	var panicErr *PanicError
+	if !errors.As(results.Err(), &panicErr) {
+		t.Fatalf("Expected PanicError but got %v", results.Err())
+	}
+
+	if panicErr.Target != "panic" || panicErr.Value != "intentional" {
+		t.Errorf("Unexpected PanicError %s", panicErr)
+	}
+
+	if !bytes.Contains(panicErr.Stack, []byte("TestEvaluatePanic")) {
+		t.Errorf("Expected stack trace to contain the panicking function")
+	}
+
+	for target, expected := range map[string]Status{
+		"dependent":   StatusSkipped,
+		"independent": StatusSucceeded,
+		"root":        StatusSkipped,
+	} {
+		actual := results.Lookup(target).Status
+		if actual != expected {
+			t.Errorf("Expected %s to be %s but got %s", target, expected, actual)
+		}
+	}
+}
+
+func TestEvaluateSoftDependencies(t *testing.T) {
+	intentional := errors.New("intentional")
+	soft := NewRule("soft", nil, func() error {
+		return intentional
+	})
+
+	evaluated := false
+	rule := NewRule("rule", nil, func() error {
+		evaluated = true
+		return nil
+	})
+	rule.SoftDependencies = []*Rule{soft}
+
+	results := Evaluate(rule)
+	if results.Lookup("soft").Err != intentional {
+		t.Errorf("Expected soft dependency to be evaluated")
+	}
+
+	if !evaluated || results.Lookup("rule").Status != StatusSucceeded {
+		t.Errorf("Expected rule to be evaluated despite soft dependency failing")
+	}
+}
+
+func TestEvaluateOrderOnly(t *testing.T) {
+	var (
+		actual []byte
+		// Protects actual byte array
+		mu sync.Mutex
+	)
+	appendRule := func(target string, b byte) *Rule {
+		return NewRule(target, nil, func() error {
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			actual = append(actual, b)
+			return nil
+		})
+	}
+
+	clean := appendRule("clean", '1')
+	build := appendRule("build", '2')
+	build.OrderOnly = []*Rule{clean}
+
+	// Order-only dependencies aren't evaluated on their own
+	results := Evaluate(build)
+	if results.Lookup("clean") != nil {
+		t.Errorf("Expected order-only dependency to not be evaluated")
+	}
+
+	// But they finish first if they are evaluated
+	actual = nil
+	all := NewRule("all", []*Rule{build, clean}, func() error {
+		return nil
+	})
+
+	err := Evaluate(all).Err()
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	expected := []byte{'1', '2'}
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("Expected order %s but got %s", expected, actual)
+	}
+}
+
+func TestEvaluateFinalizers(t *testing.T) {
+	intentional := errors.New("intentional")
+	failing := NewRule("failing", nil, func() error {
+		return intentional
+	})
+
+	var (
+		finalized int
+		// Protects finalized, as finalizers of different rules run in parallel
+		mu sync.Mutex
+	)
+	finalizer := NewRule("finalizer", nil, func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		finalized++
+		return nil
+	})
+
+	rule := NewRule("rule", []*Rule{failing}, func() error {
+		return nil
+	})
+	failing.Finalizers = []*Rule{finalizer}
+	rule.Finalizers = []*Rule{finalizer}
+
+	results := Evaluate(rule)
+	if results.Lookup("rule").Status != StatusSkipped {
+		t.Errorf("Expected rule to be skipped")
+	}
+
+	// Finalizers run after the failed rule but not the skipped one, which
+	// never started
+	if finalized != 1 {
+		t.Errorf("Expected finalizer to run 1 time but ran %d", finalized)
+	}
+
+	last := results[len(results)-1]
+	if last.Target != "finalizer" || !last.Finalizer || last.Status != StatusSucceeded {
+		t.Errorf("Expected finalizer results last")
+	}
+}
+
+func TestEvaluateFinalizersCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rule := &Rule{
+		Target: "rule",
+		Action: func(ctx *Context) error {
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	var finalizerErr error
+	rule.Finalizers = []*Rule{{
+		Target: "finalizer",
+		Action: func(ctx *Context) error {
+			finalizerErr = ctx.Err()
+			return nil
+		},
+	}}
+
+	results := new(Evaluator).EvaluateContext(ctx, rule)
+	if results.Lookup("finalizer") == nil {
+		t.Fatalf("Expected finalizer to run after cancellation")
+	}
+
+	if finalizerErr != nil {
+		t.Errorf("Expected finalizer to not be cancelled but got %s", finalizerErr)
+	}
+}
+
+func TestEvaluateFinalizersNotStarted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	finalized := false
+	finalizer := NewRule("finalizer", nil, func() error {
+		finalized = true
+		return nil
+	})
+
+	rule := NewRule("rule", nil, func() error {
+		return nil
+	})
+	rule.Finalizers = []*Rule{finalizer}
+
+	// A rule that doesn't start because the evaluation was cancelled isn't
+	// finalized
+	results := new(Evaluator).EvaluateContext(ctx, rule)
+	if results.Lookup("rule").Status != StatusSkipped {
+		t.Errorf("Expected rule to be skipped")
+	}
+
+	if finalized || results.Lookup("finalizer") != nil {
+		t.Errorf("Expected finalizer to not run")
+	}
+
+	// Nor is a rule that's up to date
+	output := filepath.Join(t.TempDir(), "output")
+	err := os.WriteFile(output, nil, 0644)
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	rule.Outputs = []string{output}
+	results = Evaluate(rule)
+	if results.Lookup("rule").Status != StatusUpToDate {
+		t.Errorf("Expected rule to be up to date")
+	}
+
+	if finalized {
+		t.Errorf("Expected finalizer to not run")
+	}
+}
+
+func TestEvaluateGroup(t *testing.T) {
+	build := NewRule("build", nil, func() error {
+		return nil
+	})
+	test := NewRule("test", nil, func() error {
+		return nil
+	})
+
+	var events []Event
+	evaluator := &Evaluator{
+		Observers: []Observer{ObserverFunc(func(event Event) {
+			events = append(events, event)
+		})},
+		Jobs: 1,
+	}
+
+	all := NewGroup("all", build, test)
+	result := evaluator.Evaluate(all).Lookup("all")
+	if result.Status != StatusSucceeded {
+		t.Errorf("Expected %s but got %s", StatusSucceeded, result.Status)
+	}
+
+	// Only members are evaluated
+	for _, event := range events {
+		if event.Rule == all {
+			t.Errorf("Expected group to not be evaluated")
+		}
+	}
+
+	// A group with nothing to evaluate is up to date if its members are
+	results := evaluator.evaluate(context.Background(), []*Rule{all}, func(rule *Rule) bool {
+		return true
+	})
+	if results.Lookup("all").Status != StatusUpToDate {
+		t.Errorf("Expected %s but got %s", StatusUpToDate, results.Lookup("all").Status)
+	}
+}
+
+func TestEvaluateGroupFailed(t *testing.T) {
+	build := NewRule("build", nil, func() error {
+		return errors.New("intentional")
+	})
+
+	result := Evaluate(NewGroup("all", build)).Lookup("all")
+	if result.Status != StatusSkipped {
+		t.Errorf("Expected %s but got %s", StatusSkipped, result.Status)
+	}
+}
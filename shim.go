@@ -0,0 +1,117 @@
+package gomake
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// defaultShimTarget is the Makefile target that makes the default target of a
+// Gomakefile whose default has no other name.
+const defaultShimTarget = "gomake-default"
+
+// WriteMakefile writes a Makefile to w with a target for every name in the
+// Gomakefile that delegates to binary, so that tools running make keep working.
+// Aliases depend on the target they're an alias of. Descriptions are written as
+// comments, binary can be overridden by setting GOMAKE and flags can be passed
+// with GOMAKEFLAGS.
+func (g *Gomakefile) WriteMakefile(w io.Writer, binary string) error {
+	var names []string
+	for target := range g.Targets {
+		if target != "" {
+			names = append(names, target)
+		}
+	}
+	sort.Strings(names)
+
+	var lines []string
+	lines = append(lines,
+		"# Generated by gomake generate-makefile. DO NOT EDIT.",
+		"",
+		fmt.Sprintf("GOMAKE ?= %s", binary),
+		"",
+	)
+
+	phony := make([]string, len(names))
+	for i, name := range names {
+		phony[i] = escapeMakeTarget(name)
+	}
+
+	rule, ok := g.Targets[""]
+	if ok {
+		defaultNames := g.Names(rule)
+		if len(defaultNames) > 0 {
+			lines = append(lines, fmt.Sprintf(".DEFAULT_GOAL := %s", escapeMakeTarget(defaultNames[0])), "")
+		} else {
+			phony = append([]string{defaultShimTarget}, phony...)
+			lines = append(lines,
+				fmt.Sprintf(".DEFAULT_GOAL := %s", defaultShimTarget),
+				"",
+				fmt.Sprintf("%s:", defaultShimTarget),
+				"\t$(GOMAKE) $(GOMAKEFLAGS)",
+				"",
+			)
+		}
+	}
+
+	for _, name := range names {
+		// Aliases delegate to their canonical name
+		rule := g.Targets[name]
+		canonical := g.Names(rule)[0]
+		if name != canonical {
+			lines = append(lines, fmt.Sprintf("%s: %s", escapeMakeTarget(name), escapeMakeTarget(canonical)), "")
+			continue
+		}
+
+		if rule.Description != "" {
+			lines = append(lines, makeComment(rule.Description)...)
+		}
+
+		// The shell quoting is escaped from make too
+		lines = append(lines,
+			fmt.Sprintf("%s:", escapeMakeTarget(name)),
+			fmt.Sprintf("\t$(GOMAKE) $(GOMAKEFLAGS) %s", strings.ReplaceAll(quote(name), "$", "$$")),
+			"",
+		)
+	}
+
+	lines = append(lines, fmt.Sprintf(".PHONY: %s", strings.Join(phony, " ")))
+
+	_, err := fmt.Fprintf(w, "%s\n", strings.Join(lines, "\n"))
+	return err
+}
+
+// escapeMakeTarget escapes the characters in target that make would
+// interpret.
+func escapeMakeTarget(target string) string {
+	var buf strings.Builder
+	for _, r := range target {
+		switch r {
+		case ':', '#', ' ', '%':
+			buf.WriteRune('\\')
+		case '$':
+			buf.WriteRune('$')
+		}
+		buf.WriteRune(r)
+	}
+
+	return buf.String()
+}
+
+// makeComment returns the lines of a make comment for text, so that a
+// newline in text doesn't end the comment.
+func makeComment(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			lines = append(lines, "#")
+			continue
+		}
+
+		lines = append(lines, "# "+line)
+	}
+
+	return lines
+}
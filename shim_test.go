@@ -0,0 +1,103 @@
+package gomake
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hinshun/gomake/pkg/cli"
+)
+
+func TestWriteMakefile(t *testing.T) {
+	gomakefile := NewGomakefile()
+	build := gomakefile.AddRule("build", nil, nil)
+	build.Description = "Builds the app"
+	gomakefile.AddAlias("b", build)
+	gomakefile.AddRule("api:test", nil, nil)
+	gomakefile.SetDefault(build)
+
+	var buf bytes.Buffer
+	err := gomakefile.WriteMakefile(&buf, "./gomake")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	expected := `# Generated by gomake generate-makefile. DO NOT EDIT.
+
+GOMAKE ?= ./gomake
+
+.DEFAULT_GOAL := build
+
+api\:test:
+	$(GOMAKE) $(GOMAKEFLAGS) api:test
+
+b: build
+
+# Builds the app
+build:
+	$(GOMAKE) $(GOMAKEFLAGS) build
+
+.PHONY: api\:test b build
+`
+	if buf.String() != expected {
+		t.Errorf("Expected %s but got %s", expected, buf.String())
+	}
+}
+
+func TestWriteMakefileMultilineDescription(t *testing.T) {
+	gomakefile := NewGomakefile()
+	build := gomakefile.AddRule("build", nil, nil)
+	build.Description = "Builds the app\n\nfor every platform\n"
+
+	var buf bytes.Buffer
+	err := gomakefile.WriteMakefile(&buf, "./gomake")
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	expected := `# Builds the app
+#
+# for every platform
+build:
+`
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("Expected %s in %s", expected, buf.String())
+	}
+}
+
+func TestGomakeGenerateMakefile(t *testing.T) {
+	gomakefile := NewGomakefile()
+	gomakefile.AddRule("build", nil, nil)
+
+	path := filepath.Join(t.TempDir(), "Makefile")
+	check := []string{"gomake", "generate-makefile", "--makefile=" + path, "--check"}
+
+	err := Gomake(gomakefile).Run(check)
+	if cli.ExitCode(err) != cli.ExitFailure {
+		t.Errorf("Expected missing Makefile to fail check but got %v", err)
+	}
+
+	err = Gomake(gomakefile).Run(check[:3])
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	err = Gomake(gomakefile).Run(check)
+	if err != nil {
+		t.Errorf("Expected generated Makefile to pass check but got %s", err)
+	}
+
+	// Adding a target makes the Makefile stale
+	gomakefile.AddRule("test", nil, nil)
+	err = Gomake(gomakefile).Run(check)
+	if err == nil || !strings.Contains(err.Error(), "out of date") {
+		t.Errorf("Expected stale Makefile to fail check but got %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "build:") {
+		t.Errorf("Expected Makefile to have build target")
+	}
+}
@@ -0,0 +1,77 @@
+package gomake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+const (
+	// DefaultGracePeriod is how long commands are given to exit after being
+	// signalled before they are killed.
+	DefaultGracePeriod = 5 * time.Second
+)
+
+var (
+	// ErrInterrupted is matched by errors from evaluations interrupted by a
+	// signal.
+	ErrInterrupted = errors.New("interrupted")
+)
+
+// InterruptError is the cause of an evaluation being cancelled by a signal.
+type InterruptError struct {
+	// Signal is the signal that was received.
+	Signal os.Signal
+}
+
+func (e *InterruptError) Error() string {
+	return fmt.Sprintf("interrupted by %s", e.Signal)
+}
+
+// Is returns whether target is ErrInterrupted.
+func (e *InterruptError) Is(target error) bool {
+	return target == ErrInterrupted
+}
+
+// notifyContext returns a copy of parent that is cancelled with an
+// *InterruptError when the process receives an interrupt signal. Only the
+// first signal is handled, so a second one terminates the process as usual.
+// The returned stop function releases the signal handler.
+func notifyContext(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, interruptSignals...)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			cancel(&InterruptError{Signal: sig})
+		case <-done:
+		}
+	}()
+
+	stop := func() {
+		signal.Stop(signals)
+		close(done)
+		cancel(context.Canceled)
+	}
+
+	return ctx, stop
+}
+
+// terminateSignal returns the signal to send to commands when ctx is done,
+// which is the signal that interrupted the evaluation if there was one.
+func terminateSignal(ctx context.Context) os.Signal {
+	var interruptErr *InterruptError
+	if errors.As(context.Cause(ctx), &interruptErr) {
+		return interruptErr.Signal
+	}
+
+	return defaultTerminateSignal
+}
@@ -0,0 +1,94 @@
+//go:build unix
+
+package gomake
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hinshun/gomake/pkg/cli"
+)
+
+func TestInterruptProcessGroup(t *testing.T) {
+	// The background sleep ignores SIGINT like any asynchronous command in a
+	// non-interactive shell, so it is only stopped by the SIGKILL after the
+	// grace period. Until then, it holds onto the output and the rule can't
+	// finish.
+	rule := NewRule("sleep", nil, nil)
+	rule.Action = func(ctx *Context) error {
+		return Run(ctx, "sh", "-c", "sleep 30 & wait")
+	}
+
+	var buf bytes.Buffer
+	evaluator := &Evaluator{
+		Stdout:      &buf,
+		Stderr:      &buf,
+		GracePeriod: 100 * time.Millisecond,
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(100*time.Millisecond, func() {
+		cancel(&InterruptError{Signal: os.Interrupt})
+	})
+
+	begin := time.Now()
+	results := evaluator.EvaluateContext(ctx, rule)
+	if results.Lookup("sleep").Err == nil {
+		t.Errorf("Expected err from interrupted rule")
+	}
+
+	if time.Since(begin) > 5*time.Second {
+		t.Errorf("Expected process group to be killed after the grace period")
+	}
+}
+
+func TestInterruptSkipsRules(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(&InterruptError{Signal: os.Interrupt})
+
+	evaluated := false
+	rule := NewRule("rule", nil, func() error {
+		evaluated = true
+		return nil
+	})
+
+	results := new(Evaluator).EvaluateContext(ctx, rule)
+	if results.Lookup("rule").Status != StatusSkipped {
+		t.Errorf("Expected rule to be skipped but got %s", results.Lookup("rule").Status)
+	}
+
+	if evaluated {
+		t.Errorf("Expected rule to not be evaluated after interrupt")
+	}
+}
+
+func TestGomakeInterrupt(t *testing.T) {
+	gomakefile := NewGomakefile()
+	gomakefile.AddAction("sleep", nil, func(ctx *Context) error {
+		// Interrupt ourselves once the signal handler is installed
+		time.AfterFunc(100*time.Millisecond, func() {
+			syscall.Kill(os.Getpid(), syscall.SIGINT)
+		})
+
+		return Run(ctx, "sleep", "30")
+	})
+
+	begin := time.Now()
+	err := Gomake(gomakefile).Run([]string{"gomake", "--silent", "sleep"})
+	if !errors.Is(err, ErrInterrupted) {
+		t.Errorf("Expected %s but got %s", ErrInterrupted, err)
+	}
+
+	if cli.ExitCode(err) != cli.ExitInterrupted {
+		t.Errorf("Expected exit code %d but got %d", cli.ExitInterrupted, cli.ExitCode(err))
+	}
+
+	if time.Since(begin) > 5*time.Second {
+		t.Errorf("Expected sleep to be stopped by the interrupt")
+	}
+}
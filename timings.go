@@ -0,0 +1,211 @@
+package gomake
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Timings is an Observer that records how long each rule takes to evaluate,
+// so that a summary of where the time went can be reported after a run.
+type Timings struct {
+	mu sync.Mutex
+
+	// rules is the list of rules in the order they started evaluating.
+	rules  []*Rule
+	starts map[*Rule]time.Time
+	ends   map[*Rule]time.Time
+}
+
+// NewTimings initializes an empty Timings.
+func NewTimings() *Timings {
+	return &Timings{
+		starts: make(map[*Rule]time.Time),
+		ends:   make(map[*Rule]time.Time),
+	}
+}
+
+// Observe records the start and finish times of rules.
+func (t *Timings) Observe(event Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch event.Kind {
+	case EventStarted:
+		// Time retried rules from their first attempt
+		_, ok := t.starts[event.Rule]
+		if ok {
+			return
+		}
+
+		t.rules = append(t.rules, event.Rule)
+		t.starts[event.Rule] = event.Time
+	case EventFinished:
+		t.ends[event.Rule] = event.Time
+	}
+}
+
+// Duration returns the wall time rule took to evaluate, or zero if it hasn't
+// finished evaluating.
+func (t *Timings) Duration(rule *Rule) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.duration(rule)
+}
+
+func (t *Timings) duration(rule *Rule) time.Duration {
+	end, ok := t.ends[rule]
+	if !ok {
+		return 0
+	}
+
+	return end.Sub(t.starts[rule])
+}
+
+// Total returns the sum of the wall time of every rule.
+func (t *Timings) Total() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var total time.Duration
+	for _, rule := range t.rules {
+		total += t.duration(rule)
+	}
+
+	return total
+}
+
+// Elapsed returns the wall time from the first rule starting to the last rule
+// finishing.
+func (t *Timings) Elapsed() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var first, last time.Time
+	for _, rule := range t.rules {
+		start, end := t.starts[rule], t.ends[rule]
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if end.After(last) {
+			last = end
+		}
+	}
+
+	if last.Before(first) {
+		return 0
+	}
+
+	return last.Sub(first)
+}
+
+// Parallelism returns the average number of rules that were evaluating at the
+// same time, which is the total rule time divided by the elapsed time.
+func (t *Timings) Parallelism() float64 {
+	elapsed := t.Elapsed()
+	if elapsed == 0 {
+		return 0
+	}
+
+	return float64(t.Total()) / float64(elapsed)
+}
+
+// CriticalPath returns the chain of dependent rules with the longest combined
+// wall time, ordered from the first rule evaluated to the last, along with
+// that combined time. Speeding up any other rule won't make the run faster.
+// Groups aren't timed, but are included when the path goes through them.
+func (t *Timings) CriticalPath() ([]*Rule, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Longest path ending at each rule and the dependency it came through
+	lengths := make(map[*Rule]time.Duration)
+	previous := make(map[*Rule]*Rule)
+
+	var length func(rule *Rule) time.Duration
+	length = func(rule *Rule) time.Duration {
+		l, ok := lengths[rule]
+		if ok {
+			return l
+		}
+
+		for _, dependency := range append(rule.pulled(), rule.OrderOnly...) {
+			// Walk through dependencies that weren't timed, such as groups,
+			// unless nothing beneath them evaluated in this run
+			dl := length(dependency)
+			_, ok := t.ends[dependency]
+			if !ok && dl == 0 {
+				continue
+			}
+
+			if dl > l || previous[rule] == nil {
+				l = dl
+				previous[rule] = dependency
+			}
+		}
+
+		l += t.duration(rule)
+		lengths[rule] = l
+		return l
+	}
+
+	var (
+		last    *Rule
+		longest time.Duration
+	)
+	for _, rule := range t.rules {
+		l := length(rule)
+		if last == nil || l > longest {
+			last = rule
+			longest = l
+		}
+	}
+
+	var path []*Rule
+	for rule := last; rule != nil; rule = previous[rule] {
+		path = append([]*Rule{rule}, path...)
+	}
+
+	return path, longest
+}
+
+// WriteReport writes a summary of the rule timings to w, with the slowest
+// rules listed first.
+func (t *Timings) WriteReport(w io.Writer) error {
+	t.mu.Lock()
+	rules := make([]*Rule, len(t.rules))
+	copy(rules, t.rules)
+	t.mu.Unlock()
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return t.Duration(rules[i]) > t.Duration(rules[j])
+	})
+
+	path, length := t.CriticalPath()
+	var targets []string
+	for _, rule := range path {
+		targets = append(targets, rule.Target)
+	}
+
+	writer := tabwriter.NewWriter(w, 1, 8, 2, ' ', 0)
+	fmt.Fprintf(writer, "TARGET\tTIME\n")
+	for _, rule := range rules {
+		fmt.Fprintf(writer, "%s\t%s\n", rule.Target, round(t.Duration(rule)))
+	}
+	fmt.Fprintf(writer, "\n")
+	fmt.Fprintf(writer, "total\t%s\n", round(t.Total()))
+	fmt.Fprintf(writer, "elapsed\t%s\n", round(t.Elapsed()))
+	fmt.Fprintf(writer, "parallelism\t%.2fx\n", t.Parallelism())
+	fmt.Fprintf(writer, "critical path\t%s\t%s\n", round(length), strings.Join(targets, " -> "))
+
+	return writer.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
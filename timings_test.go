@@ -0,0 +1,121 @@
+package gomake
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimings(t *testing.T) {
+	rule1 := NewRule("rule1", nil, nil)
+	rule2 := NewRule("rule2", nil, nil)
+	rule3 := NewRule("rule3", []*Rule{rule1, rule2}, nil)
+
+	// rule1 and rule2 run in parallel, then rule3 runs after both
+	begin := time.Now()
+	timings := NewTimings()
+	for _, event := range []Event{
+		{Kind: EventStarted, Rule: rule1, Time: begin},
+		{Kind: EventStarted, Rule: rule2, Time: begin},
+		{Kind: EventFinished, Rule: rule1, Time: begin.Add(1 * time.Second)},
+		{Kind: EventFinished, Rule: rule2, Time: begin.Add(3 * time.Second)},
+		{Kind: EventStarted, Rule: rule3, Time: begin.Add(3 * time.Second)},
+		{Kind: EventFinished, Rule: rule3, Time: begin.Add(4 * time.Second)},
+	} {
+		timings.Observe(event)
+	}
+
+	if timings.Duration(rule2) != 3*time.Second {
+		t.Errorf("Expected rule2 to take 3s but got %s", timings.Duration(rule2))
+	}
+
+	if timings.Total() != 5*time.Second {
+		t.Errorf("Expected total of 5s but got %s", timings.Total())
+	}
+
+	if timings.Elapsed() != 4*time.Second {
+		t.Errorf("Expected elapsed of 4s but got %s", timings.Elapsed())
+	}
+
+	if timings.Parallelism() != 1.25 {
+		t.Errorf("Expected parallelism of 1.25 but got %f", timings.Parallelism())
+	}
+
+	path, length := timings.CriticalPath()
+	if length != 4*time.Second {
+		t.Errorf("Expected critical path of 4s but got %s", length)
+	}
+
+	if len(path) != 2 || path[0] != rule2 || path[1] != rule3 {
+		t.Errorf("Expected critical path rule2 -> rule3 but got %v", path)
+	}
+
+	var buf bytes.Buffer
+	err := timings.WriteReport(&buf)
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	if !strings.Contains(buf.String(), "rule2 -> rule3") {
+		t.Errorf("Expected critical path in report but got %s", buf.String())
+	}
+}
+
+func TestTimingsObserveEvaluate(t *testing.T) {
+	rule1 := NewRule("rule1", nil, func() error {
+		return nil
+	})
+	rule2 := NewRule("rule2", []*Rule{rule1}, func() error {
+		return nil
+	})
+
+	timings := NewTimings()
+	evaluator := &Evaluator{
+		Observers: []Observer{timings},
+	}
+
+	err := HandleResults(evaluator.Evaluate(rule2))
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	path, _ := timings.CriticalPath()
+	if len(path) != 2 || path[0] != rule1 || path[1] != rule2 {
+		t.Errorf("Expected critical path rule1 -> rule2 but got %v", path)
+	}
+}
+
+func TestTimingsCriticalPathGroup(t *testing.T) {
+	slow := NewRule("slow", nil, func() error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	fast := NewRule("fast", nil, func() error {
+		return nil
+	})
+	all := NewGroup("all", slow, fast)
+	deploy := NewRule("deploy", []*Rule{all}, func() error {
+		return nil
+	})
+
+	timings := NewTimings()
+	evaluator := &Evaluator{
+		Observers: []Observer{timings},
+	}
+
+	err := HandleResults(evaluator.Evaluate(deploy))
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	// Groups aren't timed but the path goes through them
+	path, length := timings.CriticalPath()
+	if len(path) != 3 || path[0] != slow || path[1] != all || path[2] != deploy {
+		t.Errorf("Expected critical path slow -> all -> deploy but got %v", path)
+	}
+
+	if length < 50*time.Millisecond {
+		t.Errorf("Expected critical path of at least 50ms but got %s", length)
+	}
+}
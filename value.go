@@ -0,0 +1,104 @@
+package gomake
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrNoValue is returned when the value of a ValueRule is read by a rule
+	// that doesn't depend on it, or the ValueRule didn't succeed.
+	ErrNoValue = errors.New("no value")
+)
+
+// ValueRule is a Rule that produces a value of type T, which rules that depend
+// on it can read once it has been evaluated. Dependents are only evaluated
+// after their dependencies finish, so reading the value never races with
+// producing it.
+type ValueRule[T any] struct {
+	*Rule
+
+	mu sync.Mutex
+	// last is the value of the last evaluation that succeeded, for when the
+	// rule is up to date and isn't evaluated again.
+	last    T
+	hasLast bool
+}
+
+// NewValueRule initializes a new ValueRule with a target and dependencies,
+// whose value is produced by produce.
+func NewValueRule[T any](target string, dependencies []*Rule, produce func(ctx *Context) (T, error)) *ValueRule[T] {
+	r := &ValueRule[T]{
+		Rule: NewRule(target, dependencies, nil),
+	}
+
+	r.Action = func(ctx *Context) error {
+		value, err := produce(ctx)
+		if err != nil {
+			return err
+		}
+
+		ctx.value = value
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.last = value
+		r.hasLast = true
+		return nil
+	}
+
+	return r
+}
+
+// AddValueRule adds a ValueRule to gomakefile with a target and dependencies,
+// whose value is produced by produce.
+func AddValueRule[T any](gomakefile *Gomakefile, target string, dependencies []*Rule, produce func(ctx *Context) (T, error)) *ValueRule[T] {
+	r := NewValueRule(target, dependencies, produce)
+	gomakefile.Targets[target] = r.Rule
+	return r
+}
+
+// Value returns the value produced by r in the evaluation of ctx. It returns
+// ErrNoValue if the rule of ctx doesn't depend on r, directly, through other
+// rules or by needing it, or r didn't succeed.
+func (r *ValueRule[T]) Value(ctx *Context) (T, error) {
+	var zero T
+	if ctx.evaluation == nil {
+		return zero, fmt.Errorf("%s: not evaluated: %w", r.Target, ErrNoValue)
+	}
+
+	// Only rules that ctx's rule waits for, directly or through other rules,
+	// are guaranteed to have finished, whether or not other rules have
+	ev := ctx.evaluation
+	ev.mu.Lock()
+	state := ev.states[r.Rule]
+	dependency := r.Rule != ctx.Rule && ev.path(ctx.Rule, r.Rule) != nil
+	ev.mu.Unlock()
+
+	if state == nil || !dependency {
+		return zero, fmt.Errorf("%s: not a dependency of %s: %w", r.Target, ctx.Rule.Target, ErrNoValue)
+	}
+
+	// Needed rules may not have finished if waiting for them was cancelled
+	select {
+	case <-state.done:
+	default:
+		return zero, fmt.Errorf("%s: not finished: %w", r.Target, ErrNoValue)
+	}
+
+	switch state.result.Status {
+	case StatusSucceeded:
+		// A nil interface value isn't asserted as T
+		value, _ := state.value.(T)
+		return value, nil
+	case StatusUpToDate:
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.hasLast {
+			return r.last, nil
+		}
+	}
+
+	return zero, fmt.Errorf("%s: %s: %w", r.Target, state.result.Status, ErrNoValue)
+}
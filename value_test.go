@@ -0,0 +1,145 @@
+package gomake
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestValueRule(t *testing.T) {
+	version := NewValueRule("version", nil, func(ctx *Context) (string, error) {
+		return "v1.0.0", nil
+	})
+
+	var actual string
+	build := &Rule{
+		Target:       "build",
+		Dependencies: []*Rule{version.Rule},
+		Action: func(ctx *Context) error {
+			var err error
+			actual, err = version.Value(ctx)
+			return err
+		},
+	}
+
+	err := Evaluate(build).Err()
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	expected := "v1.0.0"
+	if actual != expected {
+		t.Errorf("Expected %s but got %s", expected, actual)
+	}
+}
+
+func TestValueRuleNotDependency(t *testing.T) {
+	version := NewValueRule("version", nil, func(ctx *Context) (string, error) {
+		return "v1.0.0", nil
+	})
+
+	build := &Rule{
+		Target: "build",
+		Action: func(ctx *Context) error {
+			_, err := version.Value(ctx)
+			return err
+		},
+	}
+
+	result := Evaluate(build).Lookup("build")
+	if !errors.Is(result.Err, ErrNoValue) {
+		t.Errorf("Expected %s but got %s", ErrNoValue, result.Err)
+	}
+}
+
+func TestValueRuleNotDependencyFinished(t *testing.T) {
+	version := NewValueRule("version", nil, func(ctx *Context) (string, error) {
+		return "v1.0.0", nil
+	})
+
+	var actual error
+	build := &Rule{
+		Target: "build",
+		Action: func(ctx *Context) error {
+			// Give version time to finish without depending on it
+			time.Sleep(20 * time.Millisecond)
+			_, actual = version.Value(ctx)
+			return nil
+		},
+	}
+
+	new(Evaluator).EvaluateAll(context.Background(), version.Rule, build)
+	if !errors.Is(actual, ErrNoValue) {
+		t.Errorf("Expected %s but got %v", ErrNoValue, actual)
+	}
+}
+
+func TestValueRuleTransitive(t *testing.T) {
+	version := NewValueRule("version", nil, func(ctx *Context) (string, error) {
+		return "v1.0.0", nil
+	})
+	generate := NewRule("generate", []*Rule{version.Rule}, nil)
+	generate.Action = func(ctx *Context) error {
+		return nil
+	}
+
+	// build needs version through generate
+	var actual string
+	build := &Rule{
+		Target: "build",
+		Action: func(ctx *Context) error {
+			err := ctx.Need(generate)
+			if err != nil {
+				return err
+			}
+
+			actual, err = version.Value(ctx)
+			return err
+		},
+	}
+
+	err := Evaluate(build).Err()
+	if err != nil {
+		t.Fatalf("Unexpected err: %s", err)
+	}
+
+	expected := "v1.0.0"
+	if actual != expected {
+		t.Errorf("Expected %s but got %s", expected, actual)
+	}
+}
+
+func TestValueRuleFailed(t *testing.T) {
+	intentional := errors.New("intentional")
+	version := NewValueRule("version", nil, func(ctx *Context) (string, error) {
+		return "", intentional
+	})
+
+	var actual error
+	build := &Rule{
+		Target:           "build",
+		SoftDependencies: []*Rule{version.Rule},
+		Action: func(ctx *Context) error {
+			_, actual = version.Value(ctx)
+			return nil
+		},
+	}
+
+	Evaluate(build)
+	if !errors.Is(actual, ErrNoValue) {
+		t.Errorf("Expected %s but got %s", ErrNoValue, actual)
+	}
+}
+
+func TestAddValueRule(t *testing.T) {
+	gomakefile := NewGomakefile()
+	paths := AddValueRule(gomakefile, "paths", nil, func(ctx *Context) ([]string, error) {
+		return []string{"a", "b"}, nil
+	})
+
+	rule, ok := gomakefile.Targets["paths"]
+	if !ok || rule != paths.Rule {
+		t.Errorf("Expected gomakefile to have target")
+	}
+}
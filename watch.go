@@ -0,0 +1,360 @@
+package gomake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultPollInterval is how often a Watcher checks inputs for changes by
+	// default.
+	DefaultPollInterval = 500 * time.Millisecond
+
+	// DefaultDebounce is how long inputs must stay unchanged before a Watcher
+	// evaluates again by default.
+	DefaultDebounce = 200 * time.Millisecond
+)
+
+var (
+	// errInputsChanged is the cause of an evaluation being cancelled by a
+	// Watcher because its inputs changed.
+	errInputsChanged = errors.New("inputs changed")
+)
+
+// Watcher evaluates a rule every time the Inputs of the rules in its
+// dependency graph change. After the first evaluation, only the rules with
+// changed inputs and their dependents are evaluated again.
+type Watcher struct {
+	// Evaluator evaluates the rules, defaulting to a new Evaluator.
+	Evaluator *Evaluator
+	// Interval is how often inputs are checked for changes, defaulting to
+	// DefaultPollInterval.
+	Interval time.Duration
+	// Debounce is how long inputs must stay unchanged before evaluating, so
+	// that a burst of changes is evaluated once. Defaults to DefaultDebounce.
+	Debounce time.Duration
+	// Output is where a status line is written after each evaluation,
+	// defaulting to os.Stdout.
+	Output io.Writer
+}
+
+// Watch evaluates roots and then evaluates them again whenever their inputs
+// change, until ctx is done. If inputs change during an evaluation, it is
+// cancelled and started over.
+func (w *Watcher) Watch(ctx context.Context, roots ...*Rule) error {
+	rules, err := sortTopologically(roots...)
+	if err != nil {
+		return err
+	}
+
+	evaluator := w.Evaluator
+	if evaluator == nil {
+		evaluator = new(Evaluator)
+	}
+
+	interval := w.Interval
+	if interval == 0 {
+		interval = DefaultPollInterval
+	}
+
+	debounce := w.Debounce
+	if debounce == 0 {
+		debounce = DefaultDebounce
+	}
+
+	output := w.Output
+	if output == nil {
+		output = os.Stdout
+	}
+
+	// upToDate are the rules that succeeded and whose inputs haven't changed
+	// since
+	upToDate := make(map[*Rule]bool)
+
+	snapshot, err := snapshotInputs(rules)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		// pending is whether there are changes to evaluate
+		pending = true
+		// lastChange is when inputs last changed
+		lastChange time.Time
+
+		// cancel cancels the running evaluation, if any
+		cancel context.CancelCauseFunc
+		done   chan Results
+		begin  time.Time
+		// changed are the rules whose inputs changed during the running
+		// evaluation, so they aren't up to date even if they succeed
+		changed map[*Rule]bool
+	)
+	defer func() {
+		// Wait for the running evaluation to stop before returning
+		if cancel != nil {
+			cancel(context.Canceled)
+			<-done
+		}
+	}()
+
+	for {
+		if pending && cancel == nil && time.Since(lastChange) >= debounce {
+			pending = false
+			begin = time.Now()
+			changed = make(map[*Rule]bool)
+
+			// Copy so that changes during the evaluation don't race with it
+			skip := make(map[*Rule]bool)
+			for rule, ok := range upToDate {
+				skip[rule] = ok
+			}
+
+			cancel, done = w.start(ctx, evaluator, roots, skip)
+		}
+
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case results := <-done:
+			cancel(nil)
+			cancel = nil
+
+			for _, result := range results {
+				upToDate[result.Rule] = result.succeeded() && !changed[result.Rule]
+			}
+
+			writeStatus(output, results, time.Since(begin), pending)
+		case <-ticker.C:
+			next, err := snapshotInputs(rules)
+			if err != nil {
+				return err
+			}
+
+			paths := snapshot.changed(next)
+			snapshot = next
+			if len(paths) == 0 {
+				continue
+			}
+
+			// Evaluate the affected rules again, starting over if they are
+			// being evaluated
+			for _, rule := range affectedRules(rules, paths) {
+				upToDate[rule] = false
+				changed[rule] = true
+			}
+			pending = true
+			lastChange = time.Now()
+
+			if cancel != nil {
+				cancel(errInputsChanged)
+			}
+		}
+	}
+}
+
+// start evaluates roots in the background, skipping the rules in skip. It
+// returns a function to cancel the evaluation and a channel for its results.
+func (w *Watcher) start(ctx context.Context, evaluator *Evaluator, roots []*Rule, skip map[*Rule]bool) (context.CancelCauseFunc, chan Results) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	done := make(chan Results, 1)
+
+	go func() {
+		done <- evaluator.evaluate(ctx, roots, func(rule *Rule) bool {
+			return skip[rule]
+		})
+	}()
+
+	return cancel, done
+}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// inputSnapshot is the version of every input file at some point in time.
+type inputSnapshot map[string]fileStamp
+
+// snapshotInputs returns the version of every file matching the Inputs of
+// rules.
+func snapshotInputs(rules []*Rule) (inputSnapshot, error) {
+	snapshot := make(inputSnapshot)
+	for _, rule := range rules {
+		paths, err := expandInputs(rule.Inputs)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range paths {
+			_, ok := snapshot[path]
+			if ok {
+				continue
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				// File was removed since it was matched
+				continue
+			}
+
+			snapshot[path] = fileStamp{
+				modTime: info.ModTime(),
+				size:    info.Size(),
+			}
+		}
+	}
+
+	return snapshot, nil
+}
+
+// changed returns the paths that were added, removed or modified in next.
+func (s inputSnapshot) changed(next inputSnapshot) []string {
+	var paths []string
+	for path, stamp := range next {
+		prev, ok := s[path]
+		if !ok || prev != stamp {
+			paths = append(paths, path)
+		}
+	}
+
+	for path := range s {
+		_, ok := next[path]
+		if !ok {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+// affectedRules returns the rules, in topological order, that have an input
+// matching one of paths or depend on such a rule.
+func affectedRules(rules []*Rule, paths []string) []*Rule {
+	affected := make(map[*Rule]bool)
+
+	var sorted []*Rule
+	for _, rule := range rules {
+		for _, dependency := range rule.pulled() {
+			if affected[dependency] {
+				affected[rule] = true
+			}
+		}
+
+		for _, path := range paths {
+			if matchInputs(rule.Inputs, path) {
+				affected[rule] = true
+			}
+		}
+
+		if affected[rule] {
+			sorted = append(sorted, rule)
+		}
+	}
+
+	return sorted
+}
+
+// expandInputs returns the paths of the files matching inputs.
+func expandInputs(inputs []string) ([]string, error) {
+	var paths []string
+	for _, input := range inputs {
+		dir, ok := strings.CutSuffix(input, "/...")
+		if !ok {
+			matches, err := filepath.Glob(input)
+			if err != nil {
+				return nil, err
+			}
+
+			paths = append(paths, matches...)
+			continue
+		}
+
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+
+			if !entry.IsDir() {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return paths, nil
+}
+
+// matchInputs returns whether path matches any of inputs.
+func matchInputs(inputs []string, path string) bool {
+	path = filepath.Clean(path)
+	for _, input := range inputs {
+		dir, ok := strings.CutSuffix(input, "/...")
+		if ok {
+			dir = filepath.Clean(dir)
+			if dir == "." || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+				return true
+			}
+			continue
+		}
+
+		ok, _ = filepath.Match(filepath.Clean(input), path)
+		if ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeStatus writes a line summarizing the results of an evaluation that
+// took elapsed, followed by the errors of failed rules.
+func writeStatus(w io.Writer, results Results, elapsed time.Duration, cancelled bool) {
+	counts := make(map[Status]int)
+	for _, result := range results {
+		// Groups aren't evaluated, so they're not worth counting
+		if result.Rule != nil && result.Rule.IsGroup() {
+			continue
+		}
+		counts[result.Status]++
+	}
+
+	var summary []string
+	for _, status := range []Status{StatusSucceeded, StatusFailed, StatusSkipped, StatusUpToDate} {
+		if counts[status] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[status], status))
+		}
+	}
+
+	state := "done"
+	switch {
+	case cancelled:
+		state = "restarting"
+	case counts[StatusFailed] > 0:
+		state = "failed"
+	}
+
+	fmt.Fprintf(w, "[%s] %s: %s in %s\n", time.Now().Format("15:04:05"), state, strings.Join(summary, ", "), round(elapsed))
+	if !cancelled {
+		results.Write(w, FormatText)
+	}
+}
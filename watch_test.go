@@ -0,0 +1,129 @@
+package gomake
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMatchInputs(t *testing.T) {
+	inputs := []string{"*.go", "pkg/..."}
+
+	for path, expected := range map[string]bool{
+		"rule.go":              true,
+		"./rule.go":            true,
+		"cmd/gomake/gomake.go": false,
+		"pkg/cli/app.go":       true,
+		"pkg":                  false,
+		"README.md":            false,
+	} {
+		if matchInputs(inputs, path) != expected {
+			t.Errorf("Expected match of %s to be %t", path, expected)
+		}
+	}
+}
+
+func TestExpandInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.go", "sub/c.txt"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, nil, 0644)
+	}
+
+	paths, err := expandInputs([]string{filepath.Join(dir, "*.txt"), filepath.Join(dir, "sub/...")})
+	if err != nil {
+		t.Errorf("Unexpected err: %s", err)
+	}
+
+	expected := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub/c.txt")}
+	if len(paths) != len(expected) || paths[0] != expected[0] || paths[1] != expected[1] {
+		t.Errorf("Expected %v but got %v", expected, paths)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	inputA := filepath.Join(dir, "a.txt")
+	inputB := filepath.Join(dir, "b.txt")
+	os.WriteFile(inputA, []byte("a"), 0644)
+	os.WriteFile(inputB, []byte("b"), 0644)
+
+	var (
+		mu     sync.Mutex
+		counts = make(map[string]int)
+	)
+	count := func(target string) func() error {
+		return func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			counts[target]++
+			return nil
+		}
+	}
+	countOf := func(target string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return counts[target]
+	}
+
+	ruleA := NewRule("a", nil, count("a"))
+	ruleA.Inputs = []string{inputA}
+	ruleB := NewRule("b", nil, count("b"))
+	ruleB.Inputs = []string{inputB}
+	root := NewRule("root", []*Rule{ruleA, ruleB}, count("root"))
+
+	var buf bytes.Buffer
+	watcher := &Watcher{
+		Interval: 10 * time.Millisecond,
+		Debounce: 20 * time.Millisecond,
+		Output:   &lockedWriter{mu: new(sync.Mutex), w: &buf},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var watching atomic.Bool
+	watching.Store(true)
+	go func() {
+		defer watching.Store(false)
+		watcher.Watch(ctx, root)
+	}()
+
+	waitFor := func(condition func() bool) {
+		deadline := time.Now().Add(5 * time.Second)
+		for !condition() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for watcher")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Everything is evaluated the first time
+	waitFor(func() bool {
+		return countOf("root") == 1
+	})
+
+	// Only the rule with changed inputs and its dependents are evaluated again
+	os.WriteFile(inputA, []byte("changed"), 0644)
+	waitFor(func() bool {
+		return countOf("root") == 2
+	})
+
+	if countOf("a") != 2 {
+		t.Errorf("Expected a to be evaluated twice but got %d", countOf("a"))
+	}
+
+	if countOf("b") != 1 {
+		t.Errorf("Expected b to be evaluated once but got %d", countOf("b"))
+	}
+
+	cancel()
+	waitFor(func() bool {
+		return !watching.Load()
+	})
+}